@@ -1,6 +1,8 @@
 package logr
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -29,9 +31,14 @@ var errUnknown = errors.New("unknown")
 type defaultLogger struct {
 	color         bool
 	prefix        bool
+	json          bool
 	name          string
 	keysAndValues map[string]string
+	values        []interface{}
 	level         int
+	verbosity     int
+	base          int
+	components    map[string]int
 	out           io.Writer
 }
 
@@ -50,7 +57,48 @@ func OptionNoPrefix(l *defaultLogger) *defaultLogger {
 	return l
 }
 
-func NewLogger(name string, f ...(func(l *defaultLogger) *defaultLogger)) logr.Logger {
+// OptionJSON writes one JSON object per line with the timestamp, the level,
+// the name of the logger, the message and all the key/values.
+func OptionJSON(l *defaultLogger) *defaultLogger {
+	l.json = true
+	return l
+}
+
+// OptionVerbosity logs the messages of V(level) when level is lower or
+// equal to verbosity. Errors are always logged.
+func OptionVerbosity(verbosity int) Option {
+	return func(l *defaultLogger) *defaultLogger {
+		l.verbosity = verbosity
+		l.base = verbosity
+		return l
+	}
+}
+
+// OptionComponents sets the verbosity of the loggers by name, e.g. -1 to
+// only log the errors of a component. Other loggers use the default
+// verbosity.
+func OptionComponents(components map[string]int) Option {
+	return func(l *defaultLogger) *defaultLogger {
+		l.components = components
+		if v, ok := components[l.name]; ok {
+			l.verbosity = v
+		}
+		return l
+	}
+}
+
+// OptionOutput writes the logs to out.
+func OptionOutput(out io.Writer) Option {
+	return func(l *defaultLogger) *defaultLogger {
+		l.out = out
+		return l
+	}
+}
+
+// Option configures the logger created by NewLogger.
+type Option func(l *defaultLogger) *defaultLogger
+
+func NewLogger(name string, f ...Option) logr.Logger {
 	log := &defaultLogger{
 		name:          name,
 		prefix:        true,
@@ -64,10 +112,17 @@ func NewLogger(name string, f ...(func(l *defaultLogger) *defaultLogger)) logr.L
 }
 
 func (c *defaultLogger) Enabled() bool {
-	return true
+	return c.level <= c.verbosity
 }
 
 func (c *defaultLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !c.Enabled() {
+		return
+	}
+	if c.json {
+		c.logJSON("info", msg, nil, keysAndValues)
+		return
+	}
 	switch len(keysAndValues) {
 	case 0:
 		c.Log("info", msg)
@@ -77,6 +132,13 @@ func (c *defaultLogger) Info(msg string, keysAndValues ...interface{}) {
 }
 
 func (c *defaultLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if c.json {
+		if err == nil {
+			err = errUnknown
+		}
+		c.logJSON("error", msg, err, keysAndValues)
+		return
+	}
 	switch len(keysAndValues) {
 	case 0:
 		if err == nil {
@@ -93,26 +155,31 @@ func (c *defaultLogger) Error(err error, msg string, keysAndValues ...interface{
 	}
 }
 
-func (c *defaultLogger) V(level int) logr.Logger {
+func (c *defaultLogger) clone() *defaultLogger {
 	return &defaultLogger{
 		out:           c.out,
 		color:         c.color,
 		name:          c.name,
 		prefix:        c.prefix,
+		json:          c.json,
 		keysAndValues: c.keysAndValues,
-		level:         level,
+		values:        c.values,
+		level:         c.level,
+		verbosity:     c.verbosity,
+		base:          c.base,
+		components:    c.components,
 	}
 }
 
+func (c *defaultLogger) V(level int) logr.Logger {
+	output := c.clone()
+	output.level = level
+	return output
+}
+
 func (c *defaultLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
-	output := &defaultLogger{
-		out:           c.out,
-		color:         c.color,
-		name:          c.name,
-		prefix:        c.prefix,
-		keysAndValues: c.keysAndValues,
-		level:         c.level,
-	}
+	output := c.clone()
+	output.values = append(append([]interface{}{}, c.values...), keysAndValues...)
 	i := 0
 	for i < len(keysAndValues) {
 		key := fmt.Sprintf("%v", keysAndValues[i])
@@ -126,14 +193,74 @@ func (c *defaultLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
 }
 
 func (c *defaultLogger) WithName(name string) logr.Logger {
-	return &defaultLogger{
-		out:           c.out,
-		color:         c.color,
-		name:          name,
-		prefix:        c.prefix,
-		keysAndValues: c.keysAndValues,
-		level:         c.level,
+	output := c.clone()
+	output.name = name
+	output.verbosity = c.base
+	if v, ok := c.components[name]; ok {
+		output.verbosity = v
+	}
+	return output
+}
+
+// logJSON writes the entry as a JSON object. The key/values keep their
+// order, a key replaces the value of the same key set before it.
+func (c *defaultLogger) logJSON(level, msg string, err error, keysAndValues []interface{}) {
+	keys := []string{"ts", "level", "logger", "msg"}
+	values := map[string]interface{}{
+		"ts":     time.Now().Format(time.RFC3339Nano),
+		"level":  level,
+		"logger": c.name,
+		"msg":    msg,
+	}
+	if c.level > 0 {
+		keys = append(keys, "v")
+		values["v"] = c.level
+	}
+	if err != nil {
+		keys = append(keys, "error")
+		values["error"] = err.Error()
 	}
+	all := append(append([]interface{}{}, c.values...), keysAndValues...)
+	for i := 0; i < len(all); i += 2 {
+		key := fmt.Sprintf("%v", all[i])
+		var value interface{}
+		if i+1 < len(all) {
+			value = all[i+1]
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		} else if key == "ts" || key == "level" || key == "logger" || key == "msg" {
+			continue
+		}
+		values[key] = value
+	}
+	output := &bytes.Buffer{}
+	output.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			output.WriteString(",")
+		}
+		k, _ := json.Marshal(key)
+		output.Write(k)
+		output.WriteString(":")
+		output.Write(jsonValue(values[key]))
+	}
+	output.WriteString("}")
+	fmt.Fprintln(c.out, output.String())
+}
+
+func jsonValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case fmt.Stringer:
+		value = v.String()
+	}
+	output, err := json.Marshal(value)
+	if err != nil {
+		output, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	return output
 }
 
 func (c *defaultLogger) Log(key string, msg string, keysAndValues ...interface{}) {
@@ -190,12 +317,17 @@ func (c *defaultLogger) colorPrint(name, log string) {
 		fmt.Fprintln(c.out, log)
 		return
 	}
+	Color(name).Fprintln(c.out, log)
+}
 
+// Color returns the color associated with a logger name so that other
+// outputs can share the same color scheme.
+func Color(name string) *color.Color {
 	foreground, ok := colorMap[name]
 	if !ok {
 		foreground = color.FgMagenta
 	}
-	color.New(foreground).Fprintln(c.out, log)
+	return color.New(foreground)
 }
 
 type MockLogger struct {
@@ -1,9 +1,14 @@
 package logr
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_NewLogger_with_option(t *testing.T) {
@@ -67,3 +72,83 @@ func Test_mockLogger(t *testing.T) {
 		t.Error("log should be enabled")
 	}
 }
+
+func Test_Color(t *testing.T) {
+	if Color("git") == nil || Color("doesnotexist") == nil {
+		t.Error("should return a color")
+	}
+}
+
+func Test_defaultLogger_json(t *testing.T) {
+	output := &bytes.Buffer{}
+	v := NewLogger("", OptionJSON, OptionOutput(output)).WithName("deploy").WithValues("version", "123")
+	v.Info("running...", "data", strings.Repeat("x", 100), "step", 1, "msg", "ignored")
+	v.Error(errors.New("failed"), "deploy failed", "version", "456", "duration", 12*time.Millisecond)
+	v.Error(nil, "unknown")
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal("should log 3 lines, current:", output.String())
+	}
+	expected := []map[string]interface{}{
+		{"level": "info", "logger": "deploy", "msg": "running...", "version": "123", "data": strings.Repeat("x", 100), "step": float64(1)},
+		{"level": "error", "logger": "deploy", "msg": "deploy failed", "error": "failed", "version": "456", "duration": "12ms"},
+		{"level": "error", "logger": "deploy", "msg": "unknown", "error": "unknown", "version": "123"},
+	}
+	for k, line := range lines {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Error("should be JSON, current:", line)
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(entry["ts"])); err != nil {
+			t.Error("should have a timestamp, current:", line)
+		}
+		delete(entry, "ts")
+		if fmt.Sprint(entry) != fmt.Sprint(expected[k]) {
+			t.Errorf("line %d should be %v, current: %s", k, expected[k], line)
+		}
+	}
+	if !strings.HasPrefix(lines[0], `{"ts":`) || !strings.Contains(lines[0], `"version":"123","data":`) {
+		t.Error("should keep the order of the keys, current:", lines[0])
+	}
+}
+
+func Test_defaultLogger_verbosity(t *testing.T) {
+	output := &bytes.Buffer{}
+	v := NewLogger("main", OptionJSON, OptionOutput(output), OptionVerbosity(1))
+	v.Info("level 0")
+	v.V(1).Info("level 1")
+	v.V(2).Info("level 2")
+	v.V(2).Error(errors.New("error"), "level 2 error")
+	if v.V(2).Enabled() || !v.V(1).Enabled() {
+		t.Error("V(2) should be disabled and V(1) enabled")
+	}
+	content := output.String()
+	if !strings.Contains(content, "level 0") || !strings.Contains(content, `"msg":"level 1","v":1`) ||
+		strings.Contains(content, `"level 2"`) || !strings.Contains(content, "level 2 error") {
+		t.Error("should filter on verbosity, current:", content)
+	}
+	text := &bytes.Buffer{}
+	w := NewLogger("main", OptionNoPrefix, OptionOutput(text))
+	w.V(1).Info("hidden")
+	w.Info("visible")
+	if text.String() != "main       visible\n" {
+		t.Errorf("should filter on verbosity, current: %q", text.String())
+	}
+}
+
+func Test_defaultLogger_components(t *testing.T) {
+	output := &bytes.Buffer{}
+	v := NewLogger("", OptionNoPrefix, OptionOutput(output), OptionComponents(map[string]int{"http": -1, "deploy": 1}))
+	v.WithName("http").Info("request")
+	v.WithName("http").Error(errors.New("error"), "request failed")
+	v.WithName("deploy").V(1).Info("step output")
+	v.WithName("release").V(1).Info("hidden")
+	v.WithName("release").Info("started")
+	expected := "http       err:error, msg:request failed                                        \n" +
+		"deploy     step output\n" +
+		"release    started\n"
+	if output.String() != expected {
+		t.Errorf("should filter by component, current: %q", output.String())
+	}
+}
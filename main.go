@@ -5,6 +5,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/go-crzy/crzy/pkg"
 	"golang.org/x/sync/errgroup"
@@ -17,12 +20,110 @@ func parse() pkg.Args {
 	flag.StringVar(&a.Head, "head", "main", "GIT branch to build from")
 	flag.BoolVar(&a.NoColor, "nocolor", false, "disable log color")
 	flag.BoolVar(&a.Version, "version", false, "crzy version")
-	flag.StringVar(&a.Lang, "template", "go", "template for language")
+	flag.StringVar(&a.LogFormat, "log-format", "", "log format, text or json")
+	verbosity := flag.Int("v", 0, "log verbosity, 1 logs the output of the steps")
+	flag.StringVar(&a.Lang, "template", "", "template for language (go, node, python, rust, java, gradle or ant), detected from the project when empty")
 	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "v" {
+			a.Verbosity = verbosity
+		}
+	})
 	return a
 }
 
+func parseInit(arguments []string) pkg.InitArgs {
+	a := pkg.InitArgs{}
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	flags.StringVar(&a.Dir, "dir", ".", "project directory")
+	flags.StringVar(&a.Lang, "template", "", "template for language, detected from the project when empty")
+	flags.BoolVar(&a.Remote, "remote", false, "add the crzy GIT remote to the project")
+	flags.BoolVar(&a.Force, "force", false, "overwrite an existing configuration file")
+	flags.Parse(arguments)
+	return a
+}
+
+// parseSecret parses the flags of the secret command and returns the
+// secrets file and the name of the secret.
+func parseSecret(arguments []string) (string, string) {
+	flags := flag.NewFlagSet("secret", flag.ExitOnError)
+	file := flags.String("file", pkg.DefaultSecretsFile, "encrypted secrets file")
+	flags.Parse(arguments)
+	return *file, flags.Arg(0)
+}
+
+// parseClient parses the flags of a client command. Flags can be set before
+// or after the version.
+func parseClient(command string, arguments []string) (pkg.ClientArgs, []string) {
+	a := pkg.ClientArgs{}
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&a.URL, "url", getenv("CRZY_URL", "http://localhost:8080"), "crzy API URL")
+	flags.StringVar(&a.Username, "username", os.Getenv("CRZY_USERNAME"), "API username")
+	flags.StringVar(&a.Password, "password", os.Getenv("CRZY_PASSWORD"), "API password")
+	flags.BoolVar(&a.JSON, "json", false, "display the JSON output")
+	flags.BoolVar(&a.NoColor, "nocolor", false, "disable color")
+	if command == "logs" {
+		flags.BoolVar(&a.Follow, "f", false, "follow the log")
+		flags.BoolVar(&a.Err, "err", false, "display the error log")
+	}
+	if command == "versions" {
+		flags.StringVar(&a.Status, "status", "", "filter versions by status")
+		flags.StringVar(&a.Branch, "branch", "", "filter versions by branch")
+		flags.StringVar(&a.Cursor, "cursor", "", "display the versions after this one")
+		flags.IntVar(&a.Limit, "limit", 0, "number of versions to display")
+	}
+	params := []string{}
+	for {
+		flags.Parse(arguments)
+		if flags.NArg() == 0 {
+			break
+		}
+		params = append(params, flags.Arg(0))
+		arguments = flags.Args()[1:]
+	}
+	return a, params
+}
+
+func getenv(key, value string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return value
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := pkg.Init(parseInit(os.Args[2:])); err != nil {
+			fmt.Println("error detected: ", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "secret" {
+		file, name := parseSecret(os.Args[2:])
+		value, err := io.ReadAll(os.Stdin)
+		if err == nil && name == "" {
+			err = errors.New("missing secret name")
+		}
+		if err == nil {
+			err = pkg.SetSecret(file, name, strings.TrimRight(string(value), "\r\n"))
+		}
+		if err != nil {
+			fmt.Println("error detected: ", err)
+			os.Exit(1)
+		}
+		return
+	}
+	for _, command := range pkg.ClientCommands {
+		if len(os.Args) > 1 && os.Args[1] == command {
+			args, params := parseClient(command, os.Args[2:])
+			if err := pkg.RunClient(command, args, params); err != nil {
+				fmt.Println("error detected: ", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 	args := parse()
 	group, ctx := errgroup.WithContext(context.Background())
 	runner, err := pkg.NewCrzy(args)
@@ -12,3 +12,31 @@ func Test_argsParser(t *testing.T) {
 		t.Error("args not parsed as expected")
 	}
 }
+
+func Test_secretParser(t *testing.T) {
+	file, name := parseSecret([]string{"-file", "my.secrets", "TOKEN"})
+	if file != "my.secrets" || name != "TOKEN" {
+		t.Error("args not parsed as expected", file, name)
+	}
+}
+
+func Test_initParser(t *testing.T) {
+	a := parseInit([]string{"-dir", "color", "-remote"})
+	if a.Dir != "color" || !a.Remote || a.Force {
+		t.Error("args not parsed as expected")
+	}
+}
+
+func Test_clientParser(t *testing.T) {
+	a, params := parseClient("logs", []string{"-url", "http://crzy:8080", "abc", "-f", "--err"})
+	if a.URL != "http://crzy:8080" || !a.Follow || !a.Err {
+		t.Error("args not parsed as expected", a)
+	}
+	if len(params) != 1 || params[0] != "abc" {
+		t.Error("version not parsed as expected", params)
+	}
+	a, _ = parseClient("versions", []string{"-status", "failure", "-branch", "main", "-limit", "5"})
+	if a.Status != "failure" || a.Branch != "main" || a.Limit != 5 {
+		t.Error("query not parsed as expected", a)
+	}
+}
@@ -3,6 +3,8 @@ package pkg
 import (
 	"encoding/json"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -17,8 +19,42 @@ type versionsHandler struct {
 }
 
 func (v *versionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte(v.state.state.listVersions()))
+	values := r.URL.Query()
+	query := versionQuery{
+		Status: values.Get("status"),
+		Branch: values.Get("branch"),
+		Cursor: values.Get("cursor"),
+	}
+	if limit := values.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"invalid limit"}`))
+			return
+		}
+		query.Limit = l
+	}
+	output, err := v.state.state.listVersions(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"invalid cursor"}`))
+		return
+	}
+	w.Write(output)
+}
+
+type liveHandler struct {
+	state *stateManager
+}
 
+func (l *liveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	output, err := l.state.state.getLive()
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+		return
+	}
+	w.Write(output)
 }
 
 type verHandler struct {
@@ -40,7 +76,16 @@ func (v *verHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(keys) == 2 && (keys[1] == "log" || keys[1] == "err") {
-		output, err := v.state.state.logVersion(keys[0], keys[1])
+		offset := 0
+		if value := r.URL.Query().Get("offset"); value != "" {
+			var err error
+			if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"message":"bad request"}`))
+				return
+			}
+		}
+		output, err := v.state.state.logVersion(keys[0], keys[1], offset)
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte(`{"message":"not found"}`))
@@ -52,10 +97,14 @@ func (v *verHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"message":"error"}`))
 }
 
-type actionHandler struct{}
+type actionHandler struct {
+	state   *stateManager
+	release chan<- event
+}
 
 type action struct {
 	Command string
+	Version string
 }
 
 func (a *actionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -71,6 +120,28 @@ func (a *actionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`{"message":"started"}`))
 		return
 	}
+	if p.Command == "deploy" && a.release != nil {
+		envs, err := a.state.state.getEnvs(p.Version)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+			return
+		}
+		if artifact := envs.get("artifact"); artifact != "" {
+			if _, err := os.Stat(artifact); err != nil {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"message":"artifact deleted"}`))
+				return
+			}
+		}
+		select {
+		case a.release <- event{id: rollbackMessage, envs: envs}:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"message":"deploying"}`))
+		case <-r.Context().Done():
+		}
+		return
+	}
 	w.WriteHeader(http.StatusBadRequest)
 	w.Write([]byte(`{"message":"bad request"}`))
 }
@@ -96,21 +167,122 @@ func (c *configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"message":"bad request"}`))
 }
 
-func newAPI(state *stateManager) http.Handler {
+type artifactsHandler struct {
+	artifacts *artifacts
+}
+
+func (a *artifactsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v0/artifacts"), "/")
+	if version == "" && r.Method == http.MethodGet {
+		w.Write(a.artifacts.listArtifacts())
+		return
+	}
+	if version == "" || r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{"message":"method not allowed"}`))
+		return
+	}
+	switch err := a.artifacts.delete(version); err {
+	case nil:
+		w.Write([]byte(`{"message":"deleted"}`))
+	case errNoArtifact:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+	case errLiveArtifact:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"version is live"}`))
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"error"}`))
+	}
+}
+
+type notificationsHandler struct {
+	notifiers *notifiers
+}
+
+func (n *notificationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{"message":"method not allowed"}`))
+		return
+	}
+	w.Write(n.notifiers.list())
+}
+
+// apiRoute is an additional route served by the API, for handlers that
+// depend on components other than the state. It replaces the default
+// handler of the same pattern.
+type apiRoute struct {
+	pattern string
+	handler http.Handler
+}
+
+func newAPI(state *stateManager, routes ...apiRoute) http.Handler {
+	handlers := map[string]http.Handler{
+		"/v0/version":       &versionHandler{},
+		"/v0/versions":      &versionsHandler{state: state},
+		"/v0/versions/":     &verHandler{state: state},
+		"/v0/live":          &liveHandler{state: state},
+		"/v0/actions":       &actionHandler{state: state},
+		"/v0/configuration": &configHandler{},
+		scriptsPath:         &scriptHandler{},
+	}
+	for _, v := range routes {
+		handlers[v.pattern] = v.handler
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/v0/version", &versionHandler{})
-	mux.Handle("/v0/versions", &versionsHandler{state: state})
-	mux.Handle("/v0/versions/", &verHandler{state: state})
-	mux.Handle("/v0/actions", &actionHandler{})
-	mux.Handle("/v0/configuration", &configHandler{})
-	mux.Handle("/v0/scripts", &scriptHandler{})
+	for k, v := range handlers {
+		mux.Handle(k, v)
+	}
 	return mux
 }
 
-type scriptHandler struct{}
+type scriptHandler struct {
+	scripts *scripts
+}
 
 func (s *scriptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"scripts":["1", "2"]}`))
-
+	keys := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, scriptsPath), "/"), "/")
+	switch {
+	case keys[0] == "" && r.Method == http.MethodGet:
+		w.Write(s.scripts.list())
+	case keys[0] != "" && len(keys) == 1 && r.Method == http.MethodGet:
+		output, err := s.scripts.get(keys[0])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+			return
+		}
+		w.Write(output)
+	case keys[0] != "" && len(keys) == 1 && r.Method == http.MethodPost:
+		switch err := s.scripts.run(keys[0]); err {
+		case nil:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"message":"started"}`))
+		case errNoScript:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		case errScriptRunning:
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message":"script is running"}`))
+		case errNoLive:
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message":"no live version"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"error"}`))
+		}
+	case len(keys) == 2 && keys[1] == "log" && r.Method == http.MethodGet:
+		output, err := s.scripts.logs(keys[0])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+			return
+		}
+		w.Write(output)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{"message":"method not allowed"}`))
+	}
 }
@@ -19,10 +19,15 @@ type sample struct {
 
 var data = []sample{
 	{name: `get_on_version_and_succeeds`, method: http.MethodGet, route: "/v0/version", input: ``, status: http.StatusOK, output: `version`},
-	{name: `get_on_versions_and_succeeds`, method: http.MethodGet, route: "/v0/versions", input: ``, status: http.StatusOK, output: `{"versions": ["123"]}`},
+	{name: `get_on_versions_and_succeeds`, method: http.MethodGet, route: "/v0/versions", input: ``, status: http.StatusOK, output: `{"versions": [{"version":"123"}]}`},
+	{name: `get_on_versions_with_query_and_succeeds`, method: http.MethodGet, route: "/v0/versions?status=success&branch=main&limit=10", input: ``, status: http.StatusOK, output: `{"versions": [{"version":"123"}]}`},
+	{name: `get_on_versions_with_wrong_cursor_and_fails`, method: http.MethodGet, route: "/v0/versions?cursor=fail", input: ``, status: http.StatusBadRequest, output: `{"message":"invalid cursor"}`},
+	{name: `get_on_versions_with_wrong_limit_and_fails`, method: http.MethodGet, route: "/v0/versions?limit=x", input: ``, status: http.StatusBadRequest, output: `{"message":"invalid limit"}`},
 	{name: `get_on_versions_and_fail`, method: http.MethodGet, route: "/v0/versions/fail/log", input: ``, status: http.StatusNotFound, output: `{"message":"not found"}`},
+	{name: `get_on_live_and_succeeds`, method: http.MethodGet, route: "/v0/live", input: ``, status: http.StatusOK, output: `{"version":"123","upstream":"localhost:8090"}`},
 	{name: `get_on_one_version_and_succeeds`, method: http.MethodGet, route: "/v0/versions/xxx", input: ``, status: http.StatusOK, output: `{"runners": {"deploy": {} }}`},
 	{name: `get_on_one_version_and_fails`, method: http.MethodGet, route: "/v0/versions/fail", input: ``, status: http.StatusNotFound, output: `{"message":"not found"}`},
+	{name: `get_on_versions_log_and_fail_due_to_offset`, method: http.MethodGet, route: "/v0/versions/xxx/log?offset=-1", input: ``, status: http.StatusBadRequest, output: `{"message":"bad request"}`},
 	{name: `get_on_one_version_subcommand_and_succeeds`, method: http.MethodGet, route: "/v0/versions/xxx/log", input: ``, status: http.StatusOK, output: "line1\nline2"},
 	{name: `get_on_one_version_subcommand_and_fails`, method: http.MethodGet, route: "/v0/versions/xxx/unknown", input: ``, status: http.StatusOK, output: `{"message":"error"}`},
 	{name: `get_on_configuration_and_succeed`, method: http.MethodGet, route: "/v0/configuration", input: "{}", status: http.StatusOK, output: `{"message":"bad request"}`},
@@ -31,7 +36,7 @@ var data = []sample{
 	{name: `post_on_action_and_succeed`, method: http.MethodPost, route: "/v0/actions", input: `{"command": "start"}`, status: http.StatusOK, output: `{"message":"started"}`},
 	{name: `post_on_action_and_fails_due_to_payload`, method: http.MethodPost, route: "/v0/actions", input: `wrong data`, status: http.StatusBadRequest, output: `{"message":"bad request"}`},
 	{name: `post_on_action_and_fails_due_to_payload`, method: http.MethodPost, route: "/v0/actions", input: `{"action": "unknown"}`, status: http.StatusBadRequest, output: `{"message":"bad request"}`},
-	{name: `get_on_scripts_and_succeeds`, method: http.MethodGet, route: "/v0/scripts", input: ``, status: http.StatusOK, output: `{"scripts":["1", "2"]}`},
+	{name: `get_on_scripts_and_succeeds`, method: http.MethodGet, route: "/v0/scripts", input: ``, status: http.StatusOK, output: `{"scripts":[]}`},
 }
 
 func Test_configuration_success(t *testing.T) {
@@ -65,3 +70,43 @@ func Test_configuration_success(t *testing.T) {
 		}
 	}
 }
+
+func Test_actionHandler_deploy(t *testing.T) {
+	release := make(chan event, 1)
+	state := &stateManager{state: &mockState{}}
+	mux := newAPI(state, apiRoute{
+		pattern: "/v0/actions",
+		handler: &actionHandler{state: state, release: release},
+	})
+	for _, v := range []sample{
+		{name: "deploy_and_succeed", input: `{"command":"deploy","version":"abc"}`, status: http.StatusAccepted, output: `{"message":"deploying"}`},
+		{name: "deploy_and_fail", input: `{"command":"deploy","version":"fail"}`, status: http.StatusNotFound, output: `{"message":"not found"}`},
+		{name: "deploy_deleted_and_fail", input: `{"command":"deploy","version":"deleted"}`, status: http.StatusConflict, output: `{"message":"artifact deleted"}`},
+	} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v0/actions", bytes.NewBufferString(v.input)))
+		if recorder.Code != v.status || recorder.Body.String() != v.output {
+			t.Errorf("%s: expect %d %s, get: %d %s", v.name, v.status, v.output, recorder.Code, recorder.Body.String())
+		}
+	}
+	e := <-release
+	if e.id != rollbackMessage || e.envs.get("version") != "abc" {
+		t.Error("should send a rollback to the release, current:", e)
+	}
+}
+
+func Test_notificationsHandler(t *testing.T) {
+	n := &notifiers{}
+	n.record(delivery{Event: eventRollback, Version: "abc", Backend: "slack", Status: deliveryDelivered, Attempts: 1}, nil)
+	mux := newAPI(&stateManager{state: &mockState{}}, apiRoute{pattern: "/v0/notifications", handler: &notificationsHandler{notifiers: n}})
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v0/notifications", nil))
+	if recorder.Code != http.StatusOK || !bytes.Contains(recorder.Body.Bytes(), []byte(`"backend":"slack","status":"delivered","attempts":1`)) {
+		t.Error("should list the deliveries, current:", recorder.Code, recorder.Body.String())
+	}
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v0/notifications", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Error("should fail with 405, current:", recorder.Code)
+	}
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+var (
+	errNoArtifact       = errors.New("noartifact")
+	errLiveArtifact     = errors.New("liveartifact")
+	errInvalidRetention = errors.New("invalidretention")
+)
+
+type retentionStruct struct {
+	Keep    int    `yaml:"keep"`
+	MaxSize string `yaml:"max_size"`
+	MaxAge  string `yaml:"max_age"`
+}
+
+type artifact struct {
+	Version string    `json:"version"`
+	Files   []string  `json:"files"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+	Live    bool      `json:"live"`
+}
+
+type dataArtifacts struct {
+	Artifacts []artifact `json:"artifacts"`
+}
+
+// artifacts keeps track of the files written for each version in the
+// execution directory, i.e. the artifact and the release logs, so that they
+// can be garbage collected. The artifact of the live version is never
+// deleted.
+type artifacts struct {
+	sync.Mutex
+	log     logr.Logger
+	keep    int
+	maxSize int64
+	maxAge  time.Duration
+	list    map[string]*artifact
+	live    string
+}
+
+func newArtifacts(log logr.Logger, r retentionStruct) (*artifacts, error) {
+	maxSize, err := parseSize(r.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	maxAge := time.Duration(0)
+	if r.MaxAge != "" {
+		maxAge, err = time.ParseDuration(r.MaxAge)
+		if err != nil || maxAge < 0 {
+			return nil, errInvalidRetention
+		}
+	}
+	if r.Keep < 0 {
+		return nil, errInvalidRetention
+	}
+	return &artifacts{
+		log:     log,
+		keep:    r.Keep,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		list:    map[string]*artifact{},
+	}, nil
+}
+
+// parseSize converts sizes like 500B, 500M or 2G into bytes. An empty size
+// means there is no limit.
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return 0, nil
+	}
+	size = strings.TrimSuffix(size, "B")
+	unit := int64(1)
+	for k, v := range map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30} {
+		if strings.HasSuffix(size, k) {
+			unit = v
+			size = strings.TrimSuffix(size, k)
+			break
+		}
+	}
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value < 0 {
+		return 0, errInvalidRetention
+	}
+	return value * unit, nil
+}
+
+// add registers files for a version.
+func (a *artifacts) add(version string, files ...string) {
+	if a == nil || version == "" {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	v, ok := a.list[version]
+	if !ok {
+		v = &artifact{Version: version, Files: []string{}, Created: time.Now()}
+		a.list[version] = v
+	}
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		found := false
+		for _, existing := range v.Files {
+			if existing == f {
+				found = true
+			}
+		}
+		if !found {
+			v.Files = append(v.Files, f)
+		}
+	}
+}
+
+func (a *artifacts) setLive(version string) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.live = version
+}
+
+func fileSize(files []string) int64 {
+	size := int64(0)
+	for _, f := range files {
+		filepath.Walk(f, func(name string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				size += info.Size()
+			}
+			return nil
+		})
+	}
+	return size
+}
+
+// sorted returns the artifacts from the newest to the oldest with their
+// current size. It must be called with the lock held.
+func (a *artifacts) sorted() []artifact {
+	output := []artifact{}
+	for _, v := range a.list {
+		output = append(output, artifact{
+			Version: v.Version,
+			Files:   append([]string{}, v.Files...),
+			Size:    fileSize(v.Files),
+			Created: v.Created,
+			Live:    v.Version == a.live,
+		})
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Created.After(output[j].Created)
+	})
+	return output
+}
+
+func (a *artifacts) listArtifacts() []byte {
+	a.Lock()
+	defer a.Unlock()
+	output, _ := json.Marshal(&dataArtifacts{Artifacts: a.sorted()})
+	return output
+}
+
+func (a *artifacts) delete(version string) error {
+	a.Lock()
+	defer a.Unlock()
+	return a.remove(version)
+}
+
+// remove deletes the files of a version. It must be called with the lock
+// held.
+func (a *artifacts) remove(version string) error {
+	v, ok := a.list[version]
+	if !ok {
+		return errNoArtifact
+	}
+	if version == a.live {
+		return errLiveArtifact
+	}
+	for _, f := range v.Files {
+		if err := os.RemoveAll(f); err != nil {
+			return err
+		}
+	}
+	delete(a.list, version)
+	return nil
+}
+
+// gc deletes the artifacts that exceed the retention policy, starting from
+// the oldest ones.
+func (a *artifacts) gc() {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	size := int64(0)
+	for k, v := range a.sorted() {
+		size += v.Size
+		if v.Live {
+			continue
+		}
+		if (a.keep > 0 && k >= a.keep) ||
+			(a.maxAge > 0 && time.Since(v.Created) > a.maxAge) ||
+			(a.maxSize > 0 && size > a.maxSize) {
+			if err := a.remove(v.Version); err != nil {
+				a.log.Error(err, "could not delete artifact", "data", v.Version)
+				continue
+			}
+			size -= v.Size
+			a.log.Info(fmt.Sprintf("artifact deleted (%d bytes)", v.Size), "data", v.Version)
+		}
+	}
+}
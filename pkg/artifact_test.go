@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+func Test_parseSize(t *testing.T) {
+	data := map[string]int64{"": 0, "12": 12, "500B": 500, "1K": 1024, "2MB": 2 << 20, "1g": 1 << 30}
+	for k, v := range data {
+		size, err := parseSize(k)
+		if err != nil || size != v {
+			t.Errorf("%s should be %d, current: %d, %v", k, v, size, err)
+		}
+	}
+	if _, err := parseSize("abc"); err != errInvalidRetention {
+		t.Error("should fail with errInvalidRetention, current:", err)
+	}
+}
+
+func Test_newArtifacts_and_fail(t *testing.T) {
+	for _, v := range []retentionStruct{{Keep: -1}, {MaxAge: "1y"}, {MaxSize: "1T"}} {
+		if _, err := newArtifacts(&log.MockLogger{}, v); err != errInvalidRetention {
+			t.Error("should fail with errInvalidRetention, current:", err)
+		}
+	}
+}
+
+func createArtifacts(t *testing.T, a *artifacts, dir string, versions ...string) {
+	for k, v := range versions {
+		filename := path.Join(dir, "go-"+v)
+		if err := os.WriteFile(filename, []byte("1234567890"), 0644); err != nil {
+			t.Error("could not create file", err)
+			t.FailNow()
+		}
+		a.add(v, filename)
+		a.list[v].Created = time.Now().Add(time.Duration(k-len(versions)) * time.Hour)
+	}
+}
+
+func Test_artifacts_gc_keep(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	a, _ := newArtifacts(&log.MockLogger{}, retentionStruct{Keep: 2})
+	createArtifacts(t, a, dir, "1", "2", "3", "4")
+	a.setLive("1")
+	a.gc()
+	for k, v := range map[string]bool{"1": true, "2": false, "3": true, "4": true} {
+		if _, err := os.Stat(path.Join(dir, "go-"+k)); (err == nil) != v {
+			t.Errorf("go-%s existence should be %t", k, v)
+		}
+	}
+}
+
+func Test_artifacts_gc_size_and_age(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	a, _ := newArtifacts(&log.MockLogger{}, retentionStruct{MaxSize: "25", MaxAge: "150m"})
+	createArtifacts(t, a, dir, "1", "2", "3", "4")
+	a.setLive("4")
+	a.gc()
+	if len(a.list) != 2 || a.list["3"] == nil || a.list["4"] == nil {
+		t.Error("should keep 2 artifacts, current:", string(a.listArtifacts()))
+	}
+	a, _ = newArtifacts(&log.MockLogger{}, retentionStruct{MaxAge: "150m"})
+	createArtifacts(t, a, dir, "1", "2", "3", "4")
+	a.gc()
+	if len(a.list) != 2 {
+		t.Error("should keep 2 artifacts, current:", string(a.listArtifacts()))
+	}
+}
+
+func Test_artifactsHandler(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	a, _ := newArtifacts(&log.MockLogger{}, retentionStruct{})
+	createArtifacts(t, a, dir, "1", "2")
+	a.setLive("2")
+	handler := &artifactsHandler{artifacts: a}
+	server := httptest.NewServer(newAPI(
+		&stateManager{state: &mockState{}},
+		apiRoute{pattern: "/v0/artifacts", handler: handler},
+		apiRoute{pattern: "/v0/artifacts/", handler: handler},
+	))
+	client := server.Client()
+	data := []sample{
+		{method: http.MethodDelete, route: "/v0/artifacts/2", status: http.StatusConflict, output: `{"message":"version is live"}`},
+		{method: http.MethodDelete, route: "/v0/artifacts/1", status: http.StatusOK, output: `{"message":"deleted"}`},
+		{method: http.MethodDelete, route: "/v0/artifacts/1", status: http.StatusNotFound, output: `{"message":"not found"}`},
+		{method: http.MethodPost, route: "/v0/artifacts", status: http.StatusMethodNotAllowed, output: `{"message":"method not allowed"}`},
+		{method: http.MethodGet, route: "/v0/artifacts", status: http.StatusOK, output: fmt.Sprintf(
+			`{"artifacts":[{"version":"2","files":["%s"],"size":10,"created":"%s","live":true}]}`,
+			path.Join(dir, "go-2"), a.list["2"].Created.Format(time.RFC3339Nano))},
+	}
+	for _, v := range data {
+		request, _ := http.NewRequest(v.method, server.URL+v.route, nil)
+		response, err := client.Do(request)
+		if err != nil {
+			t.Errorf("Should not return %v", err)
+			continue
+		}
+		if response.StatusCode != v.status {
+			t.Errorf("%s status should be %d, current: %d", v.route, v.status, response.StatusCode)
+		}
+		body, _ := io.ReadAll(response.Body)
+		if string(body) != v.output {
+			t.Errorf("expect %s, get: %s", v.output, string(body))
+		}
+	}
+}
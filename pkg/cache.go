@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+var errCachePath = errors.New("cachepath")
+
+// cacheStruct configures the cache. Install lists the files the install
+// step depends on, e.g. go.sum or package-lock.json, and Paths the
+// directories it creates in the workspace, e.g. node_modules, that are saved
+// and restored in the worktree of the next versions.
+type cacheStruct struct {
+	Install []string `yaml:"install"`
+	Paths   []string `yaml:"paths"`
+	Build   bool     `yaml:"build"`
+}
+
+// deployCache keeps track of the inputs of the install step and of the
+// artifacts built for a given git tree so that they can be reused by the
+// next versions. It also keeps the output variables of the cached steps so
+// that they are set on a hit. The cache lives as long as the store.
+type deployCache struct {
+	sync.Mutex
+	cacheStruct
+	dir       string
+	install   string
+	artifacts map[string]string
+	outputs   map[string]envVar
+}
+
+func newDeployCache(c cacheStruct, dir string) *deployCache {
+	return &deployCache{
+		cacheStruct: c,
+		dir:         dir,
+		artifacts:   map[string]string{},
+		outputs:     map[string]envVar{},
+	}
+}
+
+// installKey computes a content-addressed key from the files configured for
+// the install step and the command line. It returns an empty key when the
+// install cache is not configured.
+func (c *deployCache) installKey(workspace string, e execStruct) string {
+	if c == nil || len(c.Install) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(e.Command + " " + strings.Join(e.Args, " ") + "\n"))
+	for _, v := range c.Install {
+		h.Write([]byte(v + "\n"))
+		f, err := os.Open(path.Join(workspace, e.WorkDir, v))
+		if err != nil {
+			h.Write([]byte("missing\n"))
+			continue
+		}
+		io.Copy(h, f)
+		f.Close()
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *deployCache) isInstalled(key string) bool {
+	if c == nil || key == "" {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.install == key
+}
+
+func (c *deployCache) setInstalled(key string) {
+	if c == nil || key == "" {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.install = key
+}
+
+// save copies the paths created by the install step in the cache directory
+// of the key and, once they are all saved, removes the previous one. It
+// fails when a path is missing so that the key is not marked installed.
+func (c *deployCache) save(key, workspace string, e execStruct) error {
+	if c == nil || key == "" || len(c.Paths) == 0 {
+		return nil
+	}
+	for _, v := range c.Paths {
+		source := path.Join(workspace, e.WorkDir, v)
+		if _, err := os.Stat(source); err != nil {
+			os.RemoveAll(path.Join(c.dir, key))
+			return fmt.Errorf("%w: %s", errCachePath, v)
+		}
+		if err := copyTree(source, path.Join(c.dir, key, v)); err != nil {
+			os.RemoveAll(path.Join(c.dir, key))
+			return err
+		}
+	}
+	c.Lock()
+	previous := c.install
+	c.Unlock()
+	if previous != "" && previous != key {
+		os.RemoveAll(path.Join(c.dir, previous))
+	}
+	return nil
+}
+
+// restore copies the paths saved for the key back in the workspace.
+func (c *deployCache) restore(key, workspace string, e execStruct) error {
+	if c == nil || key == "" || len(c.Paths) == 0 {
+		return nil
+	}
+	for _, v := range c.Paths {
+		source := path.Join(c.dir, key, v)
+		if _, err := os.Stat(source); err != nil {
+			return fmt.Errorf("%w: %s", errCachePath, v)
+		}
+		if err := copyTree(source, path.Join(workspace, e.WorkDir, v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTree copies a directory. The files are copied rather than linked so
+// that a tool updating a file in place does not change the cache or the
+// other versions.
+func copyTree(source, target string) error {
+	return filepath.Walk(source, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, name)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, relative)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(destination, info.Mode()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, destination)
+		default:
+			return copyFile(name, destination)
+		}
+	})
+}
+
+// getArtifact returns the artifact built from the same tree if it still
+// exists.
+func (c *deployCache) getArtifact(tree string) string {
+	if c == nil || !c.Build || tree == "" {
+		return ""
+	}
+	c.Lock()
+	defer c.Unlock()
+	artifact, ok := c.artifacts[tree]
+	if !ok {
+		return ""
+	}
+	if _, err := os.Stat(artifact); err != nil {
+		delete(c.artifacts, tree)
+		return ""
+	}
+	return artifact
+}
+
+func (c *deployCache) setArtifact(tree, artifact string) {
+	if c == nil || !c.Build || tree == "" || artifact == "" {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.artifacts[tree] = artifact
+}
+
+// getOutput returns the output variable saved for the key, if any.
+func (c *deployCache) getOutput(key string) *envVar {
+	if c == nil || key == "" {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	output, ok := c.outputs[key]
+	if !ok {
+		return nil
+	}
+	return &output
+}
+
+// setOutput saves the output variable of the step cached with the key.
+func (c *deployCache) setOutput(key string, output *envVar) {
+	if c == nil || key == "" || output == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.outputs[key] = *output
+}
+
+// copyArtifact copies the cached artifact, a directory with its content or
+// a file that is linked to its new name when possible.
+func copyArtifact(source, target string) error {
+	if source == target {
+		return nil
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return copyTree(source, target)
+	}
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+	return copyFile(source, target)
+}
+
+func copyFile(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+func Test_installKey(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	c := newDeployCache(cacheStruct{Install: []string{"go.sum"}}, "")
+	e := execStruct{Command: "go", Args: []string{"mod", "download"}, WorkDir: "."}
+	missing := c.installKey(dir, e)
+	os.WriteFile(path.Join(dir, "go.sum"), []byte("v1"), 0644)
+	key := c.installKey(dir, e)
+	if key == "" || key == missing {
+		t.Error("key should change with the file", key)
+	}
+	if c.isInstalled(key) {
+		t.Error("key should not be installed yet")
+	}
+	c.setInstalled(key)
+	if !c.isInstalled(key) || !c.isInstalled(c.installKey(dir, e)) {
+		t.Error("key should be installed")
+	}
+	os.WriteFile(path.Join(dir, "go.sum"), []byte("v2"), 0644)
+	if c.isInstalled(c.installKey(dir, e)) {
+		t.Error("key should change after the update")
+	}
+	if newDeployCache(cacheStruct{}, "").installKey(dir, e) != "" {
+		t.Error("key should be empty without files")
+	}
+}
+
+func Test_getArtifact_and_copy(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	c := newDeployCache(cacheStruct{Build: true}, "")
+	source := path.Join(dir, "go-1")
+	c.setArtifact("tree", source)
+	if c.getArtifact("tree") != "" {
+		t.Error("artifact should not exist")
+	}
+	os.WriteFile(source, []byte("binary"), 0755)
+	c.setArtifact("tree", source)
+	if c.getArtifact("tree") != source {
+		t.Error("artifact should be cached")
+	}
+	target := path.Join(dir, "go-2")
+	if err := copyArtifact(source, target); err != nil {
+		t.Error("copy should succeed", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "binary" {
+		t.Error("artifact should be copied, current:", string(data))
+	}
+	var disabled *deployCache
+	if disabled.getArtifact("tree") != "" || disabled.isInstalled("key") {
+		t.Error("disabled cache should never hit")
+	}
+}
+
+type mockStateRecorder struct {
+	steps []step
+}
+
+func (m *mockStateRecorder) notifyStep(version, workflow, status string, step step) {
+	m.steps = append(m.steps, step)
+}
+
+func (m *mockStateRecorder) notifyCommit(version string, commit commitInfo) {
+}
+
+func Test_startFlows_with_cache(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.WriteFile(path.Join(dir, "go.sum"), []byte("v1"), 0644)
+	state := &mockStateRecorder{}
+	deploy := &deployWorkflow{
+		log:       &log.MockLogger{},
+		workspace: dir,
+		keys: map[string]execStruct{
+			"install":   {name: "install", Command: "git", Args: []string{"version"}, WorkDir: ".", Output: "git"},
+			"pre_build": {name: "pre_build", Command: "echo", Args: []string{"generated"}, WorkDir: ".", Output: "generate"},
+			"build":     {name: "build", Command: "cp", Args: []string{"go.sum", "${artifact}"}, WorkDir: "."},
+		},
+		flow:  []string{"install", "pre_build", "build"},
+		state: state,
+		cache: newDeployCache(cacheStruct{Install: []string{"go.sum"}, Build: true}, ""),
+	}
+	for _, v := range []string{"1", "2"} {
+		vars := newEnvVars(
+			envVar{Name: "version", Value: v},
+			envVar{Name: "tree", Value: "tree"},
+			envVar{Name: "artifact", Value: path.Join(dir, "go-"+v)},
+		)
+		if err := deploy.startFlows(event{envs: vars}, &vars); err != nil {
+			t.Error("flow should succeed", err)
+		}
+		if !strings.HasPrefix(vars.get("git"), "git version") || vars.get("generate") != "generated" {
+			t.Error("outputs should be set for version", v, "current:", vars)
+		}
+	}
+	expected := []string{cacheMiss, "", cacheMiss, cacheHit, cacheHit, cacheHit}
+	if len(state.steps) != len(expected) {
+		t.Error("should record 6 steps, current:", len(state.steps))
+		t.FailNow()
+	}
+	for k, v := range expected {
+		if state.steps[k].Cache != v {
+			t.Errorf("step %d should be a %s, current: %s", k, v, state.steps[k].Cache)
+		}
+	}
+	if _, err := os.Stat(path.Join(dir, "go-2")); err != nil {
+		t.Error("artifact should be reused", err)
+	}
+}
+
+func Test_save_and_restore(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	c := newDeployCache(cacheStruct{Install: []string{"package-lock.json"}, Paths: []string{"node_modules"}}, path.Join(dir, "cache"))
+	e := execStruct{Command: "npm", Args: []string{"ci"}, WorkDir: "."}
+	first := path.Join(dir, "1")
+	os.MkdirAll(path.Join(first, "node_modules", "color"), os.ModePerm)
+	os.WriteFile(path.Join(first, "node_modules", "color", "index.js"), []byte("module"), 0644)
+	if err := c.save("key", first, e); err != nil {
+		t.Error("save should succeed", err)
+	}
+	c.setInstalled("key")
+	second := path.Join(dir, "2")
+	os.MkdirAll(second, os.ModePerm)
+	if err := c.restore("key", second, e); err != nil {
+		t.Error("restore should succeed", err)
+	}
+	if data, _ := os.ReadFile(path.Join(second, "node_modules", "color", "index.js")); string(data) != "module" {
+		t.Error("dependencies should be restored, current:", string(data))
+	}
+	os.WriteFile(path.Join(second, "node_modules", "color", "index.js"), []byte("patched"), 0644)
+	if data, _ := os.ReadFile(path.Join(dir, "cache", "key", "node_modules", "color", "index.js")); string(data) != "module" {
+		t.Error("cache should not change with the worktree, current:", string(data))
+	}
+	if err := c.save("other", second, e); err != nil {
+		t.Error("save should succeed", err)
+	}
+	if _, err := os.Stat(path.Join(dir, "cache", "key")); !os.IsNotExist(err) {
+		t.Error("previous key should be removed", err)
+	}
+	if err := c.save("missing", path.Join(dir, "3"), e); !errors.Is(err, errCachePath) {
+		t.Error("save should fail with a missing path", err)
+	}
+	if _, err := os.Stat(path.Join(dir, "cache", "missing")); !os.IsNotExist(err) {
+		t.Error("partial save should be removed", err)
+	}
+}
+
+func Test_startFlows_with_artifact_directory(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "src"), os.ModePerm)
+	os.WriteFile(path.Join(dir, "package.json"), []byte("{}"), 0644)
+	os.WriteFile(path.Join(dir, "src", "index.js"), []byte("module"), 0644)
+	state := &mockStateRecorder{}
+	deploy := &deployWorkflow{
+		log:       &log.MockLogger{},
+		workspace: dir,
+		execdir:   path.Join(dir, "exec"),
+		keys: map[string]execStruct{
+			"build": {name: "build", Command: "cp", Args: []string{"-R", "src", "package.json", "${artifactDirectory}"}, WorkDir: "."},
+		},
+		flow:  []string{"build"},
+		state: state,
+		cache: newDeployCache(cacheStruct{Build: true}, ""),
+	}
+	for _, v := range []string{"1", "2"} {
+		directory := path.Join(dir, "exec", "node-"+v)
+		os.MkdirAll(directory, os.ModePerm)
+		vars := newEnvVars(
+			envVar{Name: "version", Value: v},
+			envVar{Name: "tree", Value: "tree"},
+			envVar{Name: "artifactDirectory", Value: directory},
+			envVar{Name: "artifact", Value: path.Join(directory, "package.json")},
+		)
+		if err := deploy.startFlows(event{envs: vars}, &vars); err != nil {
+			t.Error("flow should succeed", err)
+		}
+	}
+	if len(state.steps) != 2 || state.steps[1].Cache != cacheHit {
+		t.Error("build should be reused, current:", state.steps)
+	}
+	for _, v := range []string{"package.json", "src/index.js"} {
+		if _, err := os.Stat(path.Join(dir, "exec", "node-2", v)); err != nil {
+			t.Error("artifact directory should be reused", err)
+		}
+	}
+}
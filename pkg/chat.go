@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"encoding/json"
+	"text/template"
+)
+
+const (
+	teamsChat      = "teams"
+	discordChat    = "discord"
+	mattermostChat = "mattermost"
+	chatUsername   = "crzy"
+)
+
+// chatStruct configures an incoming webhook of Teams, Discord or
+// Mattermost. The template renders the text of the message.
+type chatStruct struct {
+	URL      string   `yaml:"url"`
+	Template string   `yaml:"template"`
+	Retries  int      `yaml:"retries"`
+	Events   []string `yaml:"events"`
+}
+
+// chatNotifier posts the notifications to the incoming webhook of a chat
+// with the payload it expects.
+type chatNotifier struct {
+	*webhookNotifier
+	kind string
+	text *template.Template
+}
+
+func newChatNotifier(kind string, conf chatStruct, s *secrets) (*chatNotifier, error) {
+	w, err := newWebhookNotifier(webhookStruct{URL: conf.URL, Retries: conf.Retries, Events: conf.Events}, s)
+	if err != nil {
+		return nil, err
+	}
+	text, err := newMessageTemplate(conf.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &chatNotifier{webhookNotifier: w, kind: kind, text: text}, nil
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// payload formats the text for the chat: a message card for Teams and a
+// message for Discord and Mattermost.
+func (c *chatNotifier) payload(text string, n notification) interface{} {
+	switch c.kind {
+	case teamsChat:
+		color := "2EB886"
+		if n.Error != "" {
+			color = "D00000"
+		}
+		facts := []teamsFact{{Name: "Version", Value: n.Version}}
+		for _, v := range []teamsFact{{"Branch", n.Branch}, {"Author", n.Author}, {"Commit", n.Subject}, {"Step", n.Step}} {
+			if v.Value != "" {
+				facts = append(facts, v)
+			}
+		}
+		return map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    n.Message,
+			"themeColor": color,
+			"title":      n.Message,
+			"text":       text,
+			"sections":   []map[string]interface{}{{"facts": facts}},
+		}
+	case discordChat:
+		return map[string]string{"username": chatUsername, "content": text}
+	}
+	return map[string]string{"username": chatUsername, "text": text}
+}
+
+func (c *chatNotifier) name() string {
+	return c.kind
+}
+
+func (c *chatNotifier) notify(n notification) error {
+	if !c.accept(n.Event) {
+		return nil
+	}
+	text, err := render(c.text, n)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(c.payload(text, n))
+	if err != nil {
+		return err
+	}
+	return c.post(body)
+}
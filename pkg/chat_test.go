@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_chatNotifier(t *testing.T) {
+	server, requests := newTestServer("")
+	defer server.Close()
+	n := notification{
+		Event:   eventStepFailed,
+		Version: "abc",
+		SHA:     "0123456789abcdef",
+		Step:    "test",
+		Error:   "exit status 1",
+		Message: "version abc has failed",
+	}
+	data := []struct {
+		kind string
+		key  string
+	}{
+		{kind: teamsChat, key: "text"},
+		{kind: discordChat, key: "content"},
+		{kind: mattermostChat, key: "text"},
+	}
+	for _, v := range data {
+		c, err := newChatNotifier(v.kind, chatStruct{URL: server.URL, Template: "{{.Step}} failed on {{short .SHA}}"}, nil)
+		if err != nil {
+			t.Error(v.kind, "should succeed, error:", err)
+			t.FailNow()
+		}
+		if err := c.notify(n); err != nil {
+			t.Error(v.kind, "should succeed, error:", err)
+		}
+		payload := map[string]interface{}{}
+		json.Unmarshal((<-requests).body, &payload)
+		if payload[v.key] != "test failed on 0123456" {
+			t.Error(v.kind, "should render the template, current:", payload)
+		}
+		if v.kind == teamsChat && (payload["@type"] != "MessageCard" || payload["themeColor"] != "D00000") {
+			t.Error("should send a message card, current:", payload)
+		}
+	}
+}
+
+func Test_chatNotifier_events(t *testing.T) {
+	server, requests := newTestServer("")
+	defer server.Close()
+	c, err := newChatNotifier(discordChat, chatStruct{URL: server.URL, Events: []string{eventRollback}}, nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	c.notify(notification{Event: eventDeploySucceeded, Message: "deployed"})
+	c.notify(notification{Event: eventRollback, Message: "version abc is live again"})
+	payload := map[string]string{}
+	json.Unmarshal((<-requests).body, &payload)
+	if payload["content"] != "version abc is live again" || len(requests) != 0 {
+		t.Error("should only send the configured events, current:", payload)
+	}
+	if _, err := newChatNotifier(teamsChat, chatStruct{URL: server.URL, Template: "{{.Step"}, nil); err == nil {
+		t.Error("should fail with an invalid template")
+	}
+}
@@ -0,0 +1,298 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/go-crzy/crzy/logr"
+)
+
+var (
+	errUnknownCommand = errors.New("unknowncommand")
+	errMissingVersion = errors.New("missingversion")
+)
+
+// ClientCommands are the commands of the command-line client.
+var ClientCommands = []string{"versions", "show", "logs", "deploy", "status"}
+
+// ClientArgs holds the options of the command-line client.
+type ClientArgs struct {
+	URL      string
+	Username string
+	Password string
+	JSON     bool
+	NoColor  bool
+	Follow   bool
+	Err      bool
+	Status   string
+	Branch   string
+	Cursor   string
+	Limit    int
+	Out      io.Writer
+}
+
+type client struct {
+	ClientArgs
+	http     *http.Client
+	interval time.Duration
+}
+
+// RunClient runs a command of the command-line client against a running
+// crzy instance.
+func RunClient(command string, args ClientArgs, params []string) error {
+	if args.Out == nil {
+		args.Out = os.Stdout
+	}
+	c := &client{
+		ClientArgs: args,
+		http:       &http.Client{Timeout: 10 * time.Second},
+		interval:   time.Second,
+	}
+	version := ""
+	if len(params) > 0 {
+		version = params[0]
+	}
+	switch command {
+	case "versions":
+		return c.versions()
+	case "status":
+		return c.status()
+	case "show", "logs", "deploy":
+		if version == "" {
+			return errMissingVersion
+		}
+	default:
+		return errUnknownCommand
+	}
+	switch command {
+	case "show":
+		return c.show(version)
+	case "logs":
+		return c.logs(version)
+	default:
+		return c.deploy(version)
+	}
+}
+
+// do sends a request to the API and returns the output with the status code
+// so that callers can handle a missing resource.
+func (c *client) do(method, route string, body []byte) ([]byte, int, error) {
+	request, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+route, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Username != "" || c.Password != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	output, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, response.StatusCode, err
+	}
+	if response.StatusCode >= 300 {
+		message := struct{ Message string }{}
+		json.Unmarshal(output, &message)
+		if message.Message == "" {
+			message.Message = http.StatusText(response.StatusCode)
+		}
+		return nil, response.StatusCode, fmt.Errorf("%s %s: %s", method, route, message.Message)
+	}
+	return output, response.StatusCode, nil
+}
+
+func (c *client) get(route string) ([]byte, error) {
+	output, _, err := c.do(http.MethodGet, route, nil)
+	return output, err
+}
+
+// print displays the raw JSON output when requested and returns true.
+func (c *client) print(output []byte) bool {
+	if !c.JSON {
+		return false
+	}
+	fmt.Fprintln(c.Out, string(output))
+	return true
+}
+
+func (c *client) colorize(name, text string) string {
+	if c.NoColor {
+		return text
+	}
+	return l.Color(name).Sprint(text)
+}
+
+func (c *client) versions() error {
+	values := url.Values{}
+	for k, v := range map[string]string{
+		"status": c.Status,
+		"branch": c.Branch,
+		"cursor": c.Cursor,
+	} {
+		if v != "" {
+			values.Set(k, v)
+		}
+	}
+	if c.Limit > 0 {
+		values.Set("limit", strconv.Itoa(c.Limit))
+	}
+	route := "/v0/versions"
+	if len(values) > 0 {
+		route += "?" + values.Encode()
+	}
+	output, err := c.get(route)
+	if err != nil || c.print(output) {
+		return err
+	}
+	data := dataVersion{}
+	if err := json.Unmarshal(output, &data); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "%-16s %-8s %-12s %-10s %-8s %s\n", "VERSION", "STATUS", "SHA", "BRANCH", "DURATION", "AUTHOR")
+	for _, v := range data.Versions {
+		sha := v.SHA
+		if len(sha) > 12 {
+			sha = sha[:12]
+		}
+		version := fmt.Sprintf("%-16s", v.Version)
+		if v.Live {
+			version = c.colorize("release", fmt.Sprintf("%-16s", v.Version+" *"))
+		}
+		fmt.Fprintf(c.Out, "%s %-8s %-12s %-10s %-8s %s\n", version, v.Status, sha, v.Branch, v.Duration, v.Author)
+	}
+	if data.Next != "" {
+		fmt.Fprintf(c.Out, "more versions with -cursor %s\n", data.Next)
+	}
+	return nil
+}
+
+func (c *client) show(version string) error {
+	output, err := c.get("/v0/versions/" + url.PathEscape(version))
+	if err != nil || c.print(output) {
+		return err
+	}
+	data := displayVersion{}
+	if err := json.Unmarshal(output, &data); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "version %s\n", data.Version)
+	if data.Commit != nil {
+		fmt.Fprintf(c.Out, "commit %s on %s by %s\n  %s\n", data.Commit.SHA, data.Commit.Branch, data.Commit.Author, data.Commit.Subject)
+	}
+	longest := time.Duration(0)
+	for _, w := range data.Workflows {
+		for _, s := range w.Steps {
+			if d := stepDuration(s); d > longest {
+				longest = d
+			}
+		}
+	}
+	for _, w := range data.Workflows {
+		fmt.Fprintln(c.Out, c.colorize(w.Name, fmt.Sprintf("%-10s %s", w.Name, w.Status)))
+		for _, s := range w.Steps {
+			width := 1
+			if longest > 0 {
+				width += int(30 * stepDuration(s) / longest)
+			}
+			start := ""
+			if s.StartTime != nil {
+				start = s.StartTime.Format("15:04:05")
+			}
+			duration := ""
+			if s.Duration != nil {
+				duration = *s.Duration
+			}
+			bar := c.colorize(w.Name, strings.Repeat("█", width)) + strings.Repeat(" ", 31-width)
+			fmt.Fprintf(c.Out, "  %-10s %s %s %8s %s\n", s.Name, start, bar, duration, s.Status)
+		}
+	}
+	return nil
+}
+
+func stepDuration(s step) time.Duration {
+	if s.Duration == nil {
+		return 0
+	}
+	d, _ := time.ParseDuration(*s.Duration)
+	return d
+}
+
+func (c *client) logs(version string) error {
+	file := "log"
+	if c.Err {
+		file = "err"
+	}
+	route := fmt.Sprintf("/v0/versions/%s/%s", url.PathEscape(version), file)
+	// the API returns at most maxLogLines lines so the log is read by pages
+	// starting at the first line that has not been printed.
+	printed := 0
+	for {
+		output, err := c.get(fmt.Sprintf("%s?offset=%d", route, printed))
+		if err != nil {
+			return err
+		}
+		full := len(output) > 0 && bytes.Count(output, []byte("\n"))+1 >= maxLogLines
+		if !c.Follow && !full {
+			c.Out.Write(output)
+			if len(output) > 0 && output[len(output)-1] != '\n' {
+				fmt.Fprintln(c.Out)
+			}
+			return nil
+		}
+		// a trailing partial line is kept until its newline arrives
+		pending := output[:bytes.LastIndexByte(output, '\n')+1]
+		c.Out.Write(pending)
+		printed += bytes.Count(pending, []byte("\n"))
+		if !full {
+			time.Sleep(c.interval)
+		}
+	}
+}
+
+func (c *client) deploy(version string) error {
+	body, _ := json.Marshal(&action{Command: "deploy", Version: version})
+	output, _, err := c.do(http.MethodPost, "/v0/actions", body)
+	if err != nil || c.print(output) {
+		return err
+	}
+	fmt.Fprintf(c.Out, "version %s is deploying\n", version)
+	return nil
+}
+
+func (c *client) status() error {
+	output, code, err := c.do(http.MethodGet, "/v0/live", nil)
+	if err != nil && code != http.StatusNotFound {
+		return err
+	}
+	if err != nil {
+		output = []byte(`{}`)
+	}
+	if c.print(output) {
+		return nil
+	}
+	live := liveVersion{}
+	if err := json.Unmarshal(output, &live); err != nil {
+		return err
+	}
+	if live.Version == "" {
+		fmt.Fprintln(c.Out, "no version is live")
+		return nil
+	}
+	fmt.Fprintf(c.Out, "version %s is live on %s\n", c.colorize("release", live.Version), live.Upstream)
+	return nil
+}
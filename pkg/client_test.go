@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newClientServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "username" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v0/versions":
+			if r.URL.Query().Get("status") == "failure" {
+				w.Write([]byte(`{"versions":[{"version":"def","status":"failure"}]}`))
+				return
+			}
+			w.Write([]byte(`{"versions":[{"version":"abc","status":"success","sha":"0123456789abcdef","branch":"main",` +
+				`"duration":"12ms","author":"me","live":true},{"version":"def","status":"failure"}],"next":"def"}`))
+		case "/v0/versions/abc":
+			w.Write([]byte(`{"version":"abc","commit":{"sha":"0123","author":"me","subject":"fix","branch":"main"},"workflows":[{"name":"deploy","status":"success","steps":[` +
+				`{"command":"go","name":"test","duration":"1000ms","status":"success"},` +
+				`{"command":"go","name":"build","duration":"500ms","status":"success"}]}]}`))
+		case "/v0/versions/abc/err":
+			w.Write([]byte("line1\nline2"))
+		case "/v0/live":
+			w.Write([]byte(`{"version":"abc","upstream":"localhost:8090"}`))
+		case "/v0/actions":
+			p := action{}
+			json.NewDecoder(r.Body).Decode(&p)
+			if p.Command != "deploy" || p.Version != "abc" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"not found"}`))
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"message":"deploying"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+}
+
+func Test_RunClient_and_succeed(t *testing.T) {
+	server := newClientServer(t)
+	defer server.Close()
+	data := []struct {
+		command string
+		params  []string
+		args    ClientArgs
+		output  string
+	}{
+		{command: "versions", output: "VERSION          STATUS   SHA          BRANCH     DURATION AUTHOR\n" +
+			"abc *            success  0123456789ab main       12ms     me\n" +
+			"def              failure                                   \n" +
+			"more versions with -cursor def\n"},
+		{command: "versions", args: ClientArgs{Status: "failure"}, output: "VERSION          STATUS   SHA          BRANCH     DURATION AUTHOR\n" +
+			"def              failure                                   \n"},
+		{command: "versions", args: ClientArgs{JSON: true, Status: "failure"}, output: `{"versions":[{"version":"def","status":"failure"}]}` + "\n"},
+		{command: "status", output: "version abc is live on localhost:8090\n"},
+		{command: "logs", params: []string{"abc"}, args: ClientArgs{Err: true}, output: "line1\nline2\n"},
+		{command: "deploy", params: []string{"abc"}, output: "version abc is deploying\n"},
+		{command: "show", params: []string{"abc"}, output: "version abc\n" +
+			"commit 0123 on main by me\n  fix\n" +
+			"deploy     success\n" +
+			"  test        ███████████████████████████████   1000ms success\n" +
+			"  build       ████████████████                   500ms success\n"},
+	}
+	for _, v := range data {
+		output := &bytes.Buffer{}
+		v.args.URL = server.URL
+		v.args.Username = "username"
+		v.args.Password = "password"
+		v.args.NoColor = true
+		v.args.Out = output
+		if err := RunClient(v.command, v.args, v.params); err != nil {
+			t.Error(v.command, "should succeed, error:", err)
+		}
+		if output.String() != v.output {
+			t.Errorf("%s should return %q, current: %q", v.command, v.output, output.String())
+		}
+	}
+}
+
+func Test_RunClient_and_fail(t *testing.T) {
+	server := newClientServer(t)
+	defer server.Close()
+	args := ClientArgs{URL: server.URL, Username: "username", Password: "password", Out: &bytes.Buffer{}}
+	if err := RunClient("unknown", args, nil); err != errUnknownCommand {
+		t.Error("should fail with errUnknownCommand, current:", err)
+	}
+	if err := RunClient("show", args, nil); err != errMissingVersion {
+		t.Error("should fail with errMissingVersion, current:", err)
+	}
+	if err := RunClient("deploy", args, []string{"xyz"}); err == nil || !strings.HasSuffix(err.Error(), "not found") {
+		t.Error("should fail with not found, current:", err)
+	}
+	args.Password = "wrong"
+	if err := RunClient("versions", args, nil); err == nil || !strings.HasSuffix(err.Error(), "Unauthorized") {
+		t.Error("should fail with Unauthorized, current:", err)
+	}
+}
+
+// newLogServer serves the successive contents of a log from the offset line
+// and at most maxLogLines lines, like the API.
+func newLogServer(contents ...string) *httptest.Server {
+	calls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls >= len(contents) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+			return
+		}
+		lines := strings.Split(contents[calls], "\n")
+		calls++
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset > len(lines) {
+			offset = len(lines)
+		}
+		lines = lines[offset:]
+		if len(lines) > maxLogLines {
+			lines = lines[:maxLogLines]
+		}
+		w.Write([]byte(strings.Join(lines, "\n")))
+	}))
+}
+
+func Test_logs_follow_partial_lines(t *testing.T) {
+	server := newLogServer("line1\nli", "line1\nline2\nline3", "line1\nline2\nline3\n")
+	defer server.Close()
+	output := &bytes.Buffer{}
+	c := &client{
+		ClientArgs: ClientArgs{URL: server.URL, Follow: true, Out: output},
+		http:       server.Client(),
+	}
+	if err := c.logs("abc"); err == nil {
+		t.Error("should stop with the server")
+	}
+	if output.String() != "line1\nline2\nline3\n" {
+		t.Errorf("should print complete lines, current: %q", output.String())
+	}
+}
+
+func Test_logs_follow_past_the_limit(t *testing.T) {
+	log := &strings.Builder{}
+	for i := 0; i < maxLogLines+5; i++ {
+		fmt.Fprintf(log, "line%d\n", i)
+	}
+	first := log.String()
+	fmt.Fprintf(log, "line%d\n", maxLogLines+5)
+	server := newLogServer(first, first, log.String())
+	defer server.Close()
+	output := &bytes.Buffer{}
+	c := &client{
+		ClientArgs: ClientArgs{URL: server.URL, Follow: true, Out: output},
+		http:       server.Client(),
+	}
+	if err := c.logs("abc"); err == nil {
+		t.Error("should stop with the server")
+	}
+	if output.String() != log.String() {
+		t.Errorf("should print every line, current: %d lines", strings.Count(output.String(), "\n"))
+	}
+}
+
+func Test_status_without_live_version(t *testing.T) {
+	server := newLogServer()
+	defer server.Close()
+	output := &bytes.Buffer{}
+	c := &client{ClientArgs: ClientArgs{URL: server.URL, Out: output}, http: server.Client()}
+	if err := c.status(); err != nil || output.String() != "no version is live\n" {
+		t.Error("should report no live version, current:", output.String(), err)
+	}
+}
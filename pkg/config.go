@@ -4,6 +4,8 @@ import (
 	"embed"
 	"errors"
 	"io/ioutil"
+	"os"
+	"path"
 	"runtime"
 	"sync"
 
@@ -17,8 +19,43 @@ const (
 	DefaultConfigFile = "crzy.yaml"
 	defaultLanguage   = "go"
 	golangLanguage    = "go"
+	nodeLanguage      = "node"
+	pythonLanguage    = "python"
+	rustLanguage      = "rust"
+	javaLanguage      = "java"
+	gradleLanguage    = "gradle"
+	antLanguage       = "ant"
 )
 
+// languageTemplates associates the languages to their embedded template.
+var languageTemplates = map[string]string{
+	golangLanguage: "templates/golang.yaml",
+	nodeLanguage:   "templates/node.yaml",
+	pythonLanguage: "templates/python.yaml",
+	rustLanguage:   "templates/rust.yaml",
+	javaLanguage:   "templates/java.yaml",
+	gradleLanguage: "templates/gradle.yaml",
+	antLanguage:    "templates/ant.yaml",
+}
+
+// languageFiles is the list of files used to detect the language of a
+// project, in order of precedence.
+var languageFiles = []struct {
+	filename string
+	lang     string
+}{
+	{"go.mod", golangLanguage},
+	{"Cargo.toml", rustLanguage},
+	{"package.json", nodeLanguage},
+	{"pyproject.toml", pythonLanguage},
+	{"requirements.txt", pythonLanguage},
+	{"setup.py", pythonLanguage},
+	{"pom.xml", javaLanguage},
+	{"build.gradle", gradleLanguage},
+	{"build.gradle.kts", gradleLanguage},
+	{"build.xml", antLanguage},
+}
+
 var (
 	errUnsupportedLang   = errors.New("unsupportedlang")
 	errLoadingConfigFile = errors.New("loadingfile")
@@ -31,23 +68,35 @@ type config struct {
 	Deploy   deployStruct
 	Release  releaseStruct
 	Notifier notifierStruct
-	Scripts  []string
+	Secrets  secretsStruct
+	Scripts  map[string]execStruct `yaml:"scripts"`
 }
 
 type mainStruct struct {
 	Repository string
 	Head       string
 	Color      bool
-	API        apiStruct   `yaml:"api"`
-	Proxy      proxyStruct `yaml:"proxy"`
+	Log        logStruct     `yaml:"log"`
+	Runtime    string        `yaml:"runtime"`
+	API        apiStruct     `yaml:"api"`
+	Proxy      proxyStruct   `yaml:"proxy"`
+	Tracing    tracingStruct `yaml:"tracing"`
+}
+
+type logStruct struct {
+	Format     string         `yaml:"format"`
+	Verbosity  int            `yaml:"verbosity"`
+	Components map[string]int `yaml:"components"`
 }
 
 type triggerStruct struct {
 	Version versionStruct
+	Remote  remoteStruct `yaml:"remote"`
 }
 
 type deployStruct struct {
 	Artifact artifactStruct
+	Cache    cacheStruct
 	Install  execStruct
 	Test     execStruct
 	PreBuild execStruct `yaml:"pre_build"`
@@ -58,6 +107,7 @@ type artifactStruct struct {
 	Filename  string
 	Directory string
 	Extension string
+	Retention retentionStruct
 }
 
 type versionStruct struct {
@@ -75,11 +125,13 @@ type portRangeStruct struct {
 type releaseStruct struct {
 	PortRange portRangeStruct `yaml:"port_range"`
 	Run       execStruct
+	Verify    []verifyStruct `yaml:"verify"`
 }
 
 type apiStruct struct {
 	Username, Password *string
-	Port               int `yaml:"port"`
+	Port               int    `yaml:"port"`
+	URL                string `yaml:"url"`
 }
 
 type proxyStruct struct {
@@ -103,15 +155,32 @@ func getConfig(lang string, configFile string) (*config, error) {
 }
 
 func defaultConf(lang string) (conf *config, err error) {
-	switch lang {
-	case golangLanguage:
-		yamlFile, _ := langTemplate.ReadFile("templates/golang.yaml")
-		yaml.Unmarshal(yamlFile, &conf)
-		conf.Deploy.Artifact.Extension = map[string]string{"windows": ".exe"}[runtime.GOOS]
-		return
-	default:
+	template, ok := languageTemplates[lang]
+	if !ok {
 		return nil, errUnsupportedLang
 	}
+	yamlFile, err := langTemplate.ReadFile(template)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(yamlFile, &conf); err != nil {
+		return nil, err
+	}
+	if lang == golangLanguage {
+		conf.Deploy.Artifact.Extension = map[string]string{"windows": ".exe"}[runtime.GOOS]
+	}
+	return
+}
+
+// detectLanguage guesses the language of the project in dir from the files
+// it contains. It returns an empty string when no file matches.
+func detectLanguage(dir string) string {
+	for _, v := range languageFiles {
+		if info, err := os.Stat(path.Join(dir, v.filename)); err == nil && !info.IsDir() {
+			return v.lang
+		}
+	}
+	return ""
 }
 
 type Args struct {
@@ -121,13 +190,59 @@ type Args struct {
 	NoColor    bool
 	Version    bool
 	Lang       string
+	LogFormat  string
+	// Verbosity overrides the configuration when set, including with 0.
+	Verbosity *int
 }
 
 func (c *defaultContainer) getConf(a Args) error {
-	conf, err := getConfig(defaultLanguage, a.ConfigFile)
+	lang := a.Lang
+	if lang == "" {
+		lang = detectLanguage(".")
+	}
+	if lang == "" {
+		lang = defaultLanguage
+	}
+	conf, err := getConfig(lang, a.ConfigFile)
 	if err != nil {
 		return err
 	}
+	if _, err := newArtifacts(nil, conf.Deploy.Artifact.Retention); err != nil {
+		return err
+	}
+	if _, err := newTracer(nil, conf.Main.Tracing); err != nil {
+		return err
+	}
+	if _, err := newChecks(conf.Release.Verify, conf.Main.Runtime, nil); err != nil {
+		return err
+	}
+	logs := conf.Main.Log
+	if a.LogFormat != "" {
+		logs.Format = a.LogFormat
+	}
+	if a.Verbosity != nil {
+		logs.Verbosity = *a.Verbosity
+	}
+	if logs.Format != "" || logs.Verbosity != 0 || len(logs.Components) > 0 {
+		if c.log, err = newLogger(logs, a.NoColor); err != nil {
+			return err
+		}
+	}
+	secrets, err := loadSecrets(conf.Secrets)
+	if err != nil {
+		return err
+	}
+	c.secrets = secrets
+	if _, err := newBackends(conf.Notifier, dashboardURL(conf.Main.API), secrets); err != nil {
+		return err
+	}
+	if _, err := newRemote(conf.Trigger.Remote, secrets); err != nil {
+		return err
+	}
+	if err := checkScripts(conf.Scripts); err != nil {
+		return err
+	}
+	c.log = newMaskedLogger(c.log, secrets)
 	c.config = conf
 	if a.Repository != "myrepo" || conf.Main.Repository == "" {
 		conf.Main.Repository = a.Repository
@@ -3,9 +3,12 @@ package pkg
 import (
 	"encoding/json"
 	"os"
+	"path"
 	"reflect"
 	"runtime"
 	"testing"
+
+	log "github.com/go-crzy/crzy/logr"
 )
 
 func Test_defaultConf_and_succeed(t *testing.T) {
@@ -24,6 +27,12 @@ func Test_defaultConf_and_succeed(t *testing.T) {
 		Deploy: deployStruct{
 			Artifact: artifactStruct{
 				Filename: "go-${version}",
+				Retention: retentionStruct{
+					Keep: 5,
+				},
+			},
+			Cache: cacheStruct{
+				Build: true,
 			},
 			Build: execStruct{
 				Command: "go",
@@ -71,16 +80,134 @@ func Test_defaultConf_and_succeed(t *testing.T) {
 }
 
 func Test_defaultConf_and_fail(t *testing.T) {
-	_, err := defaultConf("java")
+	_, err := defaultConf("cobol")
 	if err != errUnsupportedLang {
-		t.Error("java should not be supported for now")
+		t.Error("cobol should not be supported")
+	}
+}
+
+func Test_defaultConf_for_all_languages(t *testing.T) {
+	for k := range languageTemplates {
+		c, err := defaultConf(k)
+		if err != nil {
+			t.Error(k, "template should load, error:", err)
+			continue
+		}
+		if c.Main.Repository != "myrepo" || c.Deploy.Build.Command == "" ||
+			c.Deploy.Test.Command == "" || c.Release.Run.Command == "" {
+			t.Error(k, "template should define the repository, test, build and run")
+		}
+		if c.Release.PortRange.Min != 8090 || c.Release.PortRange.Max != 8100 {
+			t.Error(k, "template should define the port range")
+		}
 	}
 }
 
-func Test_getConfig_and_fail_java(t *testing.T) {
-	_, err := getConfig("java", "")
+func Test_getConfig_and_fail_unknown(t *testing.T) {
+	_, err := getConfig("cobol", "")
 	if err != errUnsupportedLang {
-		t.Error("java should not be supported for now")
+		t.Error("cobol should not be supported")
+	}
+}
+
+func Test_detectLanguage(t *testing.T) {
+	data := map[string]string{
+		"go.mod":       golangLanguage,
+		"package.json": nodeLanguage,
+		"setup.py":     pythonLanguage,
+		"Cargo.toml":   rustLanguage,
+		"pom.xml":      javaLanguage,
+		"build.gradle": gradleLanguage,
+		"build.xml":    antLanguage,
+		"README.md":    "",
+	}
+	for k, v := range data {
+		dir, err := os.MkdirTemp("", "crzy")
+		if err != nil {
+			t.Error("could not create tmpdir")
+			t.FailNow()
+		}
+		os.WriteFile(path.Join(dir, k), []byte{}, 0644)
+		if lang := detectLanguage(dir); lang != v {
+			t.Errorf("%s should detect %q, current: %q", k, v, lang)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_getConf_with_template(t *testing.T) {
+	c := &defaultContainer{}
+	err := c.getConf(Args{ConfigFile: DefaultConfigFile, Lang: nodeLanguage})
+	if err != nil {
+		t.Error("should load the node template", err)
+	}
+	if c.config.Release.Run.Command != "npm" {
+		t.Error("should use npm, current:", c.config.Release.Run.Command)
+	}
+	err = c.getConf(Args{ConfigFile: DefaultConfigFile, Lang: "cobol"})
+	if err != errUnsupportedLang {
+		t.Error("should fail with errUnsupportedLang, current:", err)
+	}
+}
+
+func Test_getConf_with_json_logs(t *testing.T) {
+	c := &defaultContainer{log: &log.MockLogger{}}
+	if err := c.getConf(Args{ConfigFile: DefaultConfigFile, LogFormat: jsonLogFormat}); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if _, ok := c.logger().(*log.MockLogger); ok {
+		t.Error("should create a JSON logger")
+	}
+	if err := c.getConf(Args{ConfigFile: DefaultConfigFile, LogFormat: "xml"}); err != errInvalidLogFormat {
+		t.Error("should fail with errInvalidLogFormat, error:", err)
+	}
+	c = &defaultContainer{log: &log.MockLogger{}}
+	verbosity := 1
+	if err := c.getConf(Args{ConfigFile: DefaultConfigFile, Verbosity: &verbosity}); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if _, ok := c.logger().(*log.MockLogger); ok || !c.logger().V(1).Enabled() {
+		t.Error("should create a logger with verbosity 1")
+	}
+}
+
+func Test_getConf_with_verbosity_override(t *testing.T) {
+	f, err := os.CreateTemp(".", "*.yaml")
+	if err != nil {
+		t.Error("should be able to create a file", err)
+		t.FailNow()
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("main:\n  log:\n    format: json\n    verbosity: 2\n")
+	f.Close()
+	c := &defaultContainer{log: &log.MockLogger{}}
+	if err := c.getConf(Args{ConfigFile: f.Name()}); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if !c.logger().V(2).Enabled() {
+		t.Error("should use the verbosity of the configuration")
+	}
+	verbosity := 0
+	if err := c.getConf(Args{ConfigFile: f.Name(), Verbosity: &verbosity}); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if c.logger().V(1).Enabled() {
+		t.Error("-v 0 should override the configuration")
+	}
+}
+
+func Test_getConf_with_invalid_tracing(t *testing.T) {
+	f, err := os.CreateTemp(".", "*.yaml")
+	if err != nil {
+		t.Error("should be able to create a file", err)
+		t.FailNow()
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("main:\n  tracing:\n    endpoint: localhost:4318\n")
+	f.Close()
+	c := &defaultContainer{log: &log.MockLogger{}}
+	if err := c.getConf(Args{ConfigFile: f.Name()}); err != errInvalidTracing {
+		t.Error("should fail with errInvalidTracing, error:", err)
 	}
 }
 
@@ -10,6 +10,7 @@ import (
 
 type container interface {
 	getConf(args Args) error
+	logger() logr.Logger
 	createStore() (*store, error)
 	newStateManager() *stateManager
 	newDefaultGitCommand(store store) (gitCommand, error)
@@ -17,13 +18,76 @@ type container interface {
 	newReverseProxy(u upstream) http.Handler
 	newHTTPListener(addr string) (*HTTPListener, error)
 	newSignalHandler() *signalHandler
-	createAndStartWorkflows(ctx context.Context, state *stateManager, git gitCommand, startTrigger chan event, startRelease chan event, switchUpstream func(string)) error
+	createAndStartWorkflows(ctx context.Context, state *stateManager, git gitCommand, startTrigger chan event, startRelease chan event, switchUpstream func(version, upstream string)) error
 }
 
 type defaultContainer struct {
-	log    logr.Logger
-	out    io.Writer
-	config *config
+	log       logr.Logger
+	out       io.Writer
+	config    *config
+	artifacts *artifacts
+	secrets   *secrets
+	metrics   *metrics
+	tracer    *tracer
+	notifiers *notifiers
+}
+
+// logger returns the logger built from the configuration.
+func (r *defaultContainer) logger() logr.Logger {
+	return r.log
+}
+
+// getArtifacts returns the artifacts shared by the workflows and the API.
+// The retention is validated when the configuration is loaded.
+func (r *defaultContainer) getArtifacts() *artifacts {
+	if r.artifacts == nil {
+		r.artifacts, _ = newArtifacts(r.log.WithName("store"), r.config.Deploy.Artifact.Retention)
+	}
+	return r.artifacts
+}
+
+// getMetrics returns the metrics shared by the workflows, the proxy and the
+// API.
+func (r *defaultContainer) getMetrics() *metrics {
+	if r.metrics == nil {
+		r.metrics = newMetrics()
+	}
+	return r.metrics
+}
+
+// getTracer returns the tracer shared by the workflows and the proxy, nil
+// when tracing is not configured. The configuration is validated when it is
+// loaded.
+func (r *defaultContainer) getTracer() *tracer {
+	if r.tracer == nil && r.config != nil {
+		r.tracer, _ = newTracer(r.log.WithName("trace"), r.config.Main.Tracing)
+	}
+	return r.tracer
+}
+
+// getNotifiers returns the notifiers shared by the workflows and the API.
+// The configuration is validated when it is loaded.
+func (r *defaultContainer) getNotifiers() *notifiers {
+	if r.notifiers == nil && r.config != nil {
+		r.notifiers, _ = newNotifiers(r.log.WithName("notifier"), r.config, r.secrets)
+	}
+	return r.notifiers
+}
+
+// newRoutes returns the API routes that depend on the container components.
+func (r *defaultContainer) newRoutes() []apiRoute {
+	routes := []apiRoute{}
+	if artifacts := r.getArtifacts(); artifacts != nil {
+		handler := &artifactsHandler{artifacts: artifacts}
+		routes = append(routes,
+			apiRoute{pattern: "/v0/artifacts", handler: handler},
+			apiRoute{pattern: "/v0/artifacts/", handler: handler},
+		)
+	}
+	if notifiers := r.getNotifiers(); notifiers != nil {
+		routes = append(routes, apiRoute{pattern: "/v0/notifications", handler: &notificationsHandler{notifiers: notifiers}})
+	}
+	return routes
 }
 
 var (
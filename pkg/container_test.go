@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	log "github.com/go-crzy/crzy/logr"
+	"github.com/go-logr/logr"
 )
 
 type mockContainer struct {
@@ -21,6 +22,10 @@ func (m *mockContainer) getConf(args Args) error {
 	return nil
 }
 
+func (m *mockContainer) logger() logr.Logger {
+	return &log.MockLogger{}
+}
+
 func (m *mockContainer) createStore() (*store, error) {
 	if m.step == "store" {
 		return nil, errors.New("store")
@@ -73,7 +78,7 @@ func (m *mockContainer) newSignalHandler() *signalHandler {
 	return &signalHandler{}
 }
 
-func (m *mockContainer) createAndStartWorkflows(ctx context.Context, state *stateManager, git gitCommand, startTrigger chan event, startRelease chan event, switchUpstream func(string)) error {
+func (m *mockContainer) createAndStartWorkflows(ctx context.Context, state *stateManager, git gitCommand, startTrigger chan event, startRelease chan event, switchUpstream func(version, upstream string)) error {
 	if m.step == "workflow" {
 		return errors.New("workflow")
 	}
@@ -118,14 +123,14 @@ func Test_container_mock(t *testing.T) {
 	if signal != nil {
 		t.Error("should return a signal")
 	}
-	err = c.createAndStartWorkflows(context.TODO(), nil, nil, make(chan event), make(chan event), func(string) {})
+	err = c.createAndStartWorkflows(context.TODO(), nil, nil, make(chan event), make(chan event), func(string, string) {})
 	if err != nil {
 		t.Error("should succeed, got:", err)
 	}
 	c = &mockContainer{
 		step: "workflow",
 	}
-	err = c.createAndStartWorkflows(context.TODO(), nil, nil, make(chan event), make(chan event), func(string) {})
+	err = c.createAndStartWorkflows(context.TODO(), nil, nil, make(chan event), make(chan event), func(string, string) {})
 	if err == nil {
 		t.Error("should fail")
 	}
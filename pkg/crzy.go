@@ -14,6 +14,12 @@ import (
 var (
 	ErrVersionRequested   = errors.New("version")
 	ErrWronglyInitialized = errors.New("wronginit")
+	errInvalidLogFormat   = errors.New("invalidlogformat")
+)
+
+const (
+	textLogFormat = "text"
+	jsonLogFormat = "json"
 )
 
 // DefaultRunner holds Crzy configuration. Options are embedded, instances
@@ -30,12 +36,13 @@ func NewCrzy(args Args) (*DefaultRunner, error) {
 		fmt.Fprintf(os.Stdout, "crzy version %s(%s)\n", version, commit)
 		return nil, ErrVersionRequested
 	}
-	var log logr.Logger
-	switch args.NoColor {
-	case false:
-		log = l.NewLogger("", l.OptionColor)
-	default:
-		log = l.NewLogger("")
+	logs := logStruct{Format: args.LogFormat}
+	if args.Verbosity != nil {
+		logs.Verbosity = *args.Verbosity
+	}
+	log, err := newLogger(logs, args.NoColor)
+	if err != nil {
+		return nil, err
 	}
 	container := &defaultContainer{
 		log: log,
@@ -53,11 +60,12 @@ func (c *DefaultRunner) Run(ctx context.Context) error {
 	if c.log == nil {
 		return ErrWronglyInitialized
 	}
-	log := c.log.WithName("main")
 	err := c.container.getConf(c.args)
 	if err != nil {
 		return err
 	}
+	c.log = c.container.logger()
+	log := c.log.WithName("main")
 	heading(log)
 	log.Info(fmt.Sprintf("crzy version %s(%s)", version, commit))
 	group, ctx := errgroup.WithContext(ctx)
@@ -104,6 +112,24 @@ func (c *DefaultRunner) Run(ctx context.Context) error {
 	return err
 }
 
+// newLogger creates the logger for the format, text or json, with the
+// verbosity of each component. Colors only apply to the text format.
+func newLogger(conf logStruct, noColor bool) (logr.Logger, error) {
+	options := []l.Option{
+		l.OptionVerbosity(conf.Verbosity),
+		l.OptionComponents(conf.Components),
+	}
+	switch {
+	case conf.Format == jsonLogFormat:
+		options = append(options, l.OptionJSON)
+	case conf.Format != "" && conf.Format != textLogFormat:
+		return nil, errInvalidLogFormat
+	case !noColor:
+		options = append(options, l.OptionColor)
+	}
+	return l.NewLogger("", options...), nil
+}
+
 func heading(log logr.Logger) {
 	log.Info("")
 	log.Info(" █▀▀ █▀▀█ ▀▀█ █░░█")
@@ -15,6 +15,20 @@ func Test_NewCrzy(t *testing.T) {
 	}
 }
 
+func Test_newLogger(t *testing.T) {
+	for _, v := range []string{"", textLogFormat, jsonLogFormat} {
+		if _, err := newLogger(logStruct{Format: v, Verbosity: 1}, true); err != nil {
+			t.Error(v, "should succeed, error:", err)
+		}
+	}
+	if _, err := newLogger(logStruct{Format: "xml"}, false); err != errInvalidLogFormat {
+		t.Error("should fail with errInvalidLogFormat, error:", err)
+	}
+	if _, err := NewCrzy(Args{LogFormat: "xml"}); err != errInvalidLogFormat {
+		t.Error("should fail with errInvalidLogFormat, error:", err)
+	}
+}
+
 func Test_uninitilizedRunner(t *testing.T) {
 	c := &DefaultRunner{}
 	err := c.Run(context.TODO())
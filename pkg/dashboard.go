@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+//go:embed dashboard/*
+var dashboardFiles embed.FS
+
+const dashboardPath = "/ui/"
+
+// dashboardURL returns the public URL of the API used in the links to the
+// dashboard, by default on localhost.
+func dashboardURL(conf apiStruct) string {
+	if conf.URL != "" {
+		return strings.TrimRight(conf.URL, "/")
+	}
+	port := conf.Port
+	if port == 0 {
+		port = 8080
+	}
+	return fmt.Sprintf("http://localhost:%d", port)
+}
+
+// newDashboard serves the embedded single-page dashboard. It only relies on
+// the /v0 API and does not load any external resource so that it works
+// offline.
+func newDashboard() http.Handler {
+	files, _ := fs.Sub(dashboardFiles, "dashboard")
+	fileServer := http.StripPrefix(dashboardPath, http.FileServer(http.FS(files)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == dashboardPath[:len(dashboardPath)-1] {
+			http.Redirect(w, r, dashboardPath, http.StatusFound)
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
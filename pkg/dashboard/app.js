@@ -0,0 +1,176 @@
+"use strict";
+
+const state = { version: null, log: "log", live: null };
+
+function $(id) {
+  return document.getElementById(id);
+}
+
+function el(tag, attrs, text) {
+  const e = document.createElement(tag);
+  Object.keys(attrs || {}).forEach((k) => e.setAttribute(k, attrs[k]));
+  if (text !== undefined) {
+    e.textContent = text;
+  }
+  return e;
+}
+
+async function api(route, options) {
+  const response = await fetch("/v0" + route, options);
+  if (!response.ok) {
+    throw new Error(route + ": " + response.status);
+  }
+  const type = response.headers.get("content-type") || "";
+  const body = await response.text();
+  if (type.indexOf("json") >= 0 || body.charAt(0) === "{") {
+    return JSON.parse(body);
+  }
+  return body;
+}
+
+function message(text) {
+  $("message").textContent = text;
+}
+
+function durationMs(step) {
+  if (!step.duration) {
+    return 0;
+  }
+  return parseInt(step.duration, 10) || 0;
+}
+
+async function refreshLive() {
+  try {
+    state.live = await api("/live");
+    $("live").textContent =
+      "live: " + state.live.version + " → " + state.live.upstream;
+  } catch (e) {
+    state.live = null;
+    $("live").textContent = "no version live";
+  }
+}
+
+async function refreshVersions() {
+  const data = await api("/versions");
+  const list = $("versions");
+  list.innerHTML = "";
+  (data.versions || []).forEach((summary) => {
+    const version = summary.version;
+    const item = el("li", { title: [summary.sha, summary.author].join(" ") }, version);
+    item.appendChild(el("span", { class: "summary " + summary.status }, summary.duration));
+    if (version === state.version) {
+      item.classList.add("selected");
+    }
+    if (summary.live) {
+      item.classList.add("live");
+    }
+    item.onclick = () => select(version);
+    list.appendChild(item);
+  });
+}
+
+function renderWorkflow(workflow) {
+  const box = el("div", { class: "workflow" });
+  const title = el("h3", {}, workflow.name + " ");
+  title.appendChild(el("span", { class: workflow.status }, workflow.status));
+  box.appendChild(title);
+  const steps = workflow.steps || [];
+  const total = steps.reduce((sum, s) => sum + durationMs(s), 0) || 1;
+  steps.forEach((s) => {
+    const line = el("div", { class: "step" });
+    line.appendChild(el("span", { class: "name" }, s.name));
+    const bar = el("span", { class: "bar " + (s.status || workflow.status) });
+    bar.style.width = Math.max(2, (400 * durationMs(s)) / total) + "px";
+    line.appendChild(bar);
+    const start = s.start_time ? new Date(s.start_time).toLocaleTimeString() : "";
+    line.appendChild(
+      el("span", { class: "meta" }, [start, s.duration || "", s.status || ""].join(" "))
+    );
+    box.appendChild(line);
+  });
+  return box;
+}
+
+async function refreshDetails() {
+  if (!state.version) {
+    return;
+  }
+  const data = await api("/versions/" + encodeURIComponent(state.version));
+  $("title").textContent = "version " + data.version;
+  $("commit").textContent = data.commit
+    ? [data.commit.sha.substring(0, 12), data.commit.author, data.commit.subject].join(" · ")
+    : "";
+  $("deploy").hidden = !!(state.live && state.live.version === data.version);
+  const workflows = $("workflows");
+  workflows.innerHTML = "";
+  (data.workflows || []).forEach((w) => workflows.appendChild(renderWorkflow(w)));
+  $("logs").hidden = false;
+  try {
+    const output = await api(
+      "/versions/" + encodeURIComponent(state.version) + "/" + state.log
+    );
+    $("output").textContent = typeof output === "string" ? output : JSON.stringify(output);
+  } catch (e) {
+    $("output").textContent = "no log available";
+  }
+}
+
+function select(version) {
+  state.version = version;
+  window.location.hash = version;
+  refresh();
+}
+
+async function refresh() {
+  try {
+    await refreshLive();
+    await refreshVersions();
+    await refreshDetails();
+    message("updated " + new Date().toLocaleTimeString());
+  } catch (e) {
+    message(e.message);
+  }
+}
+
+document.querySelectorAll("[data-log]").forEach((button) => {
+  button.onclick = () => {
+    document.querySelectorAll("[data-log]").forEach((b) => b.classList.remove("selected"));
+    button.classList.add("selected");
+    state.log = button.getAttribute("data-log");
+    refreshDetails();
+  };
+});
+
+document.querySelectorAll("[data-command]").forEach((button) => {
+  button.onclick = async () => {
+    try {
+      const result = await api("/actions", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ command: button.getAttribute("data-command") }),
+      });
+      message(result.message || "done");
+    } catch (e) {
+      message(e.message);
+    }
+  };
+});
+
+$("deploy").onclick = async () => {
+  try {
+    const result = await api("/actions", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ command: "deploy", version: state.version }),
+    });
+    message(result.message || "done");
+  } catch (e) {
+    message(e.message);
+  }
+};
+
+if (window.location.hash.length > 1) {
+  state.version = decodeURIComponent(window.location.hash.substring(1));
+}
+refresh();
+setInterval(refresh, 3000);
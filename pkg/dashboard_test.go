@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_newDashboard_and_succeed(t *testing.T) {
+	server := httptest.NewServer(newDashboard())
+	client := server.Client()
+	for _, v := range []string{"/ui/", "/ui/app.js", "/ui/style.css"} {
+		response, err := client.Get(server.URL + v)
+		if err != nil {
+			t.Errorf("Should not return %v", err)
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("%s status should be 200, current: %d", v, response.StatusCode)
+		}
+		body, _ := io.ReadAll(response.Body)
+		if v == "/ui/" && !strings.Contains(string(body), "<title>crzy</title>") {
+			t.Error("should return the dashboard page, current:", string(body))
+		}
+	}
+}
+
+func Test_newDashboard_and_redirect(t *testing.T) {
+	for _, v := range []string{"/", "/ui"} {
+		recorder := httptest.NewRecorder()
+		newDashboard().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, v, nil))
+		result := recorder.Result()
+		if result.StatusCode != http.StatusFound || result.Header.Get("Location") != dashboardPath {
+			t.Errorf("%s should redirect to %s, current: %d", v, dashboardPath, result.StatusCode)
+		}
+	}
+}
+
+func Test_captureAndTrigger_and_dashboard(t *testing.T) {
+	g := &gitServer{
+		repoName: "color.git",
+		state:    &stateManager{state: &mockState{}},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	recorder := httptest.NewRecorder()
+	g.captureAndTrigger(next).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	if recorder.Result().StatusCode != http.StatusOK {
+		t.Error("should serve the dashboard, current:", recorder.Result().StatusCode)
+	}
+}
+
+func Test_dashboardURL(t *testing.T) {
+	if v := dashboardURL(apiStruct{}); v != "http://localhost:8080" {
+		t.Error("should default to localhost, current:", v)
+	}
+	if v := dashboardURL(apiStruct{Port: 9000, URL: "https://crzy.example.com/"}); v != "https://crzy.example.com" {
+		t.Error("should use the url, current:", v)
+	}
+}
@@ -16,7 +16,11 @@ type deployWorkflow struct {
 	keys      map[string]execStruct
 	flow      []string
 	state     stateClient
-	slack     *slackNotifier
+	notifiers *notifiers
+	cache     *deployCache
+	artifacts *artifacts
+	metrics   *metrics
+	tracer    *tracer
 }
 
 func (w *deployWorkflow) start(ctx context.Context, action <-chan event, release, trigger chan<- event) error {
@@ -28,28 +32,23 @@ func (w *deployWorkflow) start(ctx context.Context, action <-chan event, release
 			switch action.id {
 			case triggeredMessage:
 				vars := newEnvVars(action.envs...)
-				if _, err := vars.toMap(); err != nil {
-					log.Error(err, "could not map envs")
-					trigger <- event{id: deployedMessage}
-					continue
-				}
 				artifactDirectory, err := vars.replace(w.Artifact.Directory)
 				if err != nil {
 					log.Error(err, "could not transform directory")
-					trigger <- event{id: deployedMessage}
+					trigger <- event{id: deployedMessage, envs: vars, trace: action.trace}
 					continue
 				}
 				artifactDirectory = path.Join(w.execdir, artifactDirectory)
 				if err := os.MkdirAll(artifactDirectory, os.ModeDir|os.ModePerm); err != nil {
 					log.Error(err, "could not create directory", "data", artifactDirectory)
-					trigger <- event{id: deployedMessage}
+					trigger <- event{id: deployedMessage, envs: vars, trace: action.trace}
 					continue
 				}
 				vars.addOne("artifactDirectory", artifactDirectory)
 				artifactFilename, err := vars.replace(w.Artifact.Filename + w.Artifact.Extension)
 				if err != nil {
 					log.Error(err, "could not transform filename")
-					trigger <- event{id: deployedMessage}
+					trigger <- event{id: deployedMessage, envs: vars, trace: action.trace}
 					continue
 				}
 				vars.addOne("artifactFilename", artifactFilename)
@@ -57,13 +56,17 @@ func (w *deployWorkflow) start(ctx context.Context, action <-chan event, release
 				vars.addOne("artifact", artifact)
 
 				if err := w.startFlows(action, &vars); err != nil {
+					w.metrics.deploy(runnerStatusFailed)
 					log.Error(err, "deploy execution failed...")
-					trigger <- event{id: deployedMessage}
+					trigger <- event{id: deployedMessage, envs: vars, trace: action.trace}
 					continue
 				}
+				w.artifacts.add(vars.get("version"), w.artifactPath(vars))
+				w.metrics.deploy(runnerStatusDone)
+				w.notifiers.notify(newNotification(eventDeploySucceeded, vars))
 				log.Info("deploy execution succeeded...")
-				release <- event{id: deployedMessage, envs: vars}
-				trigger <- event{id: deployedMessage}
+				release <- event{id: deployedMessage, envs: vars, trace: action.trace}
+				trigger <- event{id: deployedMessage, envs: vars, trace: action.trace}
 			}
 		case <-ctx.Done():
 			return nil
@@ -71,23 +74,77 @@ func (w *deployWorkflow) start(ctx context.Context, action <-chan event, release
 	}
 }
 
+// artifactPath returns the directory of the artifact when each version has
+// its own, e.g. for node or java, and the artifact file otherwise.
+func (w *deployWorkflow) artifactPath(vars envVars) string {
+	if directory := vars.get("artifactDirectory"); directory != "" && path.Clean(directory) != path.Clean(w.execdir) {
+		return directory
+	}
+	return vars.get("artifact")
+}
+
 func (w *deployWorkflow) startFlows(action event, vars *envVars) error {
-	log := w.log
+	log := w.log.WithName("deploy")
+	workspace := vars.get("workspace")
+	if workspace == "" {
+		workspace = w.workspace
+	}
+	tree := vars.get("tree")
+	cached := w.cache.getArtifact(tree)
+	built := w.cache != nil && w.cache.Build && tree != ""
 	for _, v := range w.flow {
 		cmd := w.keys[v]
 		cmd.log = log
 		if cmd.Command == "" {
 			continue
 		}
-		log.Info("running...", "data", v)
 		workflow := &workflow{
-			log:     log,
-			version: action.envs.get("version"),
-			name:    "deploy",
-			basedir: w.workspace,
-			envs:    *vars,
-			state:   w.state,
+			log:       log,
+			version:   action.envs.get("version"),
+			name:      "deploy",
+			basedir:   workspace,
+			envs:      *vars,
+			state:     w.state,
+			tracer:    w.tracer,
+			trace:     action.trace,
+			notifiers: w.notifiers,
+		}
+		key := ""
+		switch {
+		case v == "install":
+			key = w.cache.installKey(workspace, cmd)
+			if w.cache.isInstalled(key) {
+				err := w.cache.restore(key, workspace, cmd)
+				if err == nil {
+					log.Info("inputs unchanged, skipping...", "data", v)
+					workflow.skip(&cmd)
+					if e := w.cache.getOutput(key); e != nil {
+						vars.add(*e)
+					}
+					continue
+				}
+				log.Error(err, "could not restore dependencies, installing...", "data", v)
+			}
+			if key != "" {
+				workflow.cache = cacheMiss
+			}
+		case (v == "pre_build" || v == "build") && cached != "":
+			if v == "build" {
+				if err := copyArtifact(cached, w.artifactPath(*vars)); err != nil {
+					log.Error(err, "could not reuse artifact", "data", cached)
+					return err
+				}
+			}
+			log.Info("tree already built, skipping...", "data", v)
+			workflow.skip(&cmd)
+			if e := w.cache.getOutput(tree + "/" + v); e != nil {
+				vars.add(*e)
+			}
+			continue
+		case v == "build" && built:
+			workflow.cache = cacheMiss
 		}
+		log.Info("running...", "data", v)
 		e, err := workflow.execute(&cmd)
 		if err != nil {
 			return err
@@ -95,6 +152,24 @@ func (w *deployWorkflow) startFlows(action event, vars *envVars) error {
 		if e != nil {
 			vars.add(*e)
 		}
+		switch v {
+		case "install":
+			if err := w.cache.save(key, workspace, w.keys[v]); err != nil {
+				log.Error(err, "could not save dependencies", "data", v)
+				continue
+			}
+			w.cache.setInstalled(key)
+			w.cache.setOutput(key, e)
+		case "pre_build":
+			if built {
+				w.cache.setOutput(tree+"/"+v, e)
+			}
+		case "build":
+			w.cache.setArtifact(tree, w.artifactPath(*vars))
+			if built {
+				w.cache.setOutput(tree+"/"+v, e)
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const defaultEmailSubject = "[crzy] {{.Message}}"
+
+var errInvalidEmail = errors.New("invalidemail")
+
+// emailStruct configures the notifications by email. The password can
+// reference the secrets with ${}. The subject and the template render the
+// subject and the body of the email.
+type emailStruct struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"`
+	Template string   `yaml:"template"`
+	Events   []string `yaml:"events"`
+}
+
+// emailNotifier sends the notifications with SMTP.
+type emailNotifier struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	subject  *template.Template
+	template *template.Template
+	events   map[string]bool
+}
+
+// newEmailNotifier returns nil when no host is configured.
+func newEmailNotifier(conf emailStruct, s *secrets) (*emailNotifier, error) {
+	if conf.Host == "" {
+		return nil, nil
+	}
+	if conf.From == "" || len(conf.To) == 0 {
+		return nil, errInvalidEmail
+	}
+	port := conf.Port
+	if port == 0 {
+		port = 25
+	}
+	e := &emailNotifier{
+		addr:   net.JoinHostPort(conf.Host, strconv.Itoa(port)),
+		from:   conf.From,
+		to:     conf.To,
+		events: map[string]bool{},
+	}
+	if conf.Username != "" {
+		envs := s.envs()
+		password, err := envs.replace(conf.Password)
+		if err != nil {
+			return nil, err
+		}
+		e.auth = smtp.PlainAuth("", conf.Username, password, conf.Host)
+	}
+	subject := conf.Subject
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	var err error
+	if e.subject, err = newMessageTemplate(subject); err != nil {
+		return nil, err
+	}
+	if e.template, err = newMessageTemplate(conf.Template); err != nil {
+		return nil, err
+	}
+	for _, v := range conf.Events {
+		e.events[v] = true
+	}
+	return e, nil
+}
+
+// message formats the email with the details of the version after the body.
+func (e *emailNotifier) message(n notification) ([]byte, error) {
+	subject, err := render(e.subject, n)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render(e.template, n)
+	if err != nil {
+		return nil, err
+	}
+	output := &strings.Builder{}
+	fmt.Fprintf(output, "From: %s\r\n", e.from)
+	fmt.Fprintf(output, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(output, "Subject: %s\r\n", strings.ReplaceAll(subject, "\n", " "))
+	fmt.Fprintf(output, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(output, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(output, "%s\r\n\r\n", body)
+	for _, v := range [][2]string{
+		{"version", n.Version}, {"branch", n.Branch}, {"commit", n.SHA},
+		{"author", n.Author}, {"subject", n.Subject}, {"step", n.Step},
+	} {
+		if v[1] != "" {
+			fmt.Fprintf(output, "%s: %s\r\n", v[0], v[1])
+		}
+	}
+	if n.Output != "" {
+		fmt.Fprintf(output, "\r\n%s\r\n", strings.ReplaceAll(n.Output, "\n", "\r\n"))
+	}
+	return []byte(output.String()), nil
+}
+
+func (e *emailNotifier) name() string {
+	return "email"
+}
+
+func (e *emailNotifier) accept(event string) bool {
+	return len(e.events) == 0 || e.events[event]
+}
+
+func (e *emailNotifier) notify(n notification) error {
+	if !e.accept(n.Event) {
+		return nil
+	}
+	msg, err := e.message(n)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(e.addr, e.auth, e.from, e.to, msg)
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP accepts one email and sends its data on the channel.
+func fakeSMTP(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error("could not listen", err)
+		t.FailNow()
+	}
+	data := make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "220 localhost ESMTP\r\n")
+		message := &strings.Builder{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				fmt.Fprintf(conn, "250 localhost\r\n")
+			case command == "DATA":
+				fmt.Fprintf(conn, "354 go ahead\r\n")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					message.WriteString(line)
+				}
+				data <- message.String()
+				fmt.Fprintf(conn, "250 queued\r\n")
+			case command == "QUIT":
+				fmt.Fprintf(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprintf(conn, "250 OK\r\n")
+			}
+		}
+	}()
+	return listener.Addr().String(), data
+}
+
+func Test_emailNotifier(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	p := 0
+	fmt.Sscanf(port, "%d", &p)
+	e, err := newEmailNotifier(emailStruct{
+		Host:     host,
+		Port:     p,
+		From:     "crzy@example.com",
+		To:       []string{"ops@example.com"},
+		Subject:  "{{upper .Event}} {{.Version}}",
+		Template: "{{.Step}} has failed",
+	}, nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	err = e.notify(notification{
+		Event: eventStepFailed, Version: "abc", Branch: "main", Step: "test",
+		Output: "--- FAIL: Test_main\nFAIL", Message: "version abc has failed",
+	})
+	if err != nil {
+		t.Error("should send the email, error:", err)
+		t.FailNow()
+	}
+	message := <-data
+	for _, v := range []string{
+		"To: ops@example.com\r\n",
+		"Subject: STEP_FAILED abc\r\n",
+		"\r\n\r\ntest has failed\r\n",
+		"branch: main\r\n",
+		"--- FAIL: Test_main\r\nFAIL\r\n",
+	} {
+		if !strings.Contains(message, v) {
+			t.Errorf("email should contain %q, current: %q", v, message)
+		}
+	}
+}
+
+func Test_newEmailNotifier(t *testing.T) {
+	e, err := newEmailNotifier(emailStruct{}, nil)
+	if err != nil || e != nil {
+		t.Error("should be disabled without host", err)
+	}
+	if _, err := newEmailNotifier(emailStruct{Host: "localhost"}, nil); err != errInvalidEmail {
+		t.Error("should fail with errInvalidEmail, error:", err)
+	}
+	e, err = newEmailNotifier(emailStruct{Host: "localhost", From: "crzy@example.com", To: []string{"ops@example.com"}, Events: []string{eventRollback}}, nil)
+	if err != nil || e.addr != "localhost:25" {
+		t.Error("should default to port 25", err)
+	}
+	if err := e.notify(notification{Event: eventDeploySucceeded}); err != nil {
+		t.Error("should ignore the event, error:", err)
+	}
+}
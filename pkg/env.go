@@ -1,9 +1,11 @@
 package pkg
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
 	"os"
-	"regexp"
+	"strings"
 )
 
 type envVar struct {
@@ -15,10 +17,24 @@ type envVars []envVar
 
 var (
 	errMissingEnv    = errors.New("missing")
-	errDuplicateKeys = errors.New("dupkeys")
-	envPattern       = regexp.MustCompile(`(\$\{[a-zA-Z0-9_]*\})`)
+	errInvalidEnv    = errors.New("invalidenv")
+	errUnknownFunc   = errors.New("unknownfunc")
+	errUnclosedBrace = errors.New("unclosedbrace")
 )
 
+// envFuncs are the functions that can be applied to a variable, e.g.
+// ${short(version)}.
+var envFuncs = map[string]func(string) string{
+	"short": func(v string) string {
+		if len(v) > 7 {
+			return v[:7]
+		}
+		return v
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
 func newEnvVars(evs ...envVar) envVars { return append(envVars{}, evs...) }
 
 func (evs *envVars) add(e ...envVar) { *evs = append(*evs, e...) }
@@ -38,37 +54,166 @@ func (evs *envVars) get(name string) string {
 
 // replace replaces variables identified with ${} in param with their
 // values picked from the envs map. If one value is missing, it gets it
-// from the environment variables.
+// from the environment variables. ${name:-default} uses a default when the
+// variable is empty or missing, ${name:?message} fails with a message,
+// ${func(name)} applies short, lower or upper and $${name} keeps ${name} as
+// is. Defaults and function arguments can reference other variables.
 func (evs *envVars) replace(param string) (string, error) {
-	envs, err := evs.toMap()
-	if err != nil {
-		return "", err
+	return expand(evs.toMap(), param)
+}
+
+func expand(envs map[string]string, param string) (string, error) {
+	output := strings.Builder{}
+	for i := 0; i < len(param); i++ {
+		switch {
+		case strings.HasPrefix(param[i:], "$${"):
+			output.WriteString("${")
+			i += 2
+		case strings.HasPrefix(param[i:], "${"):
+			end := closingBrace(param, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("%w: %s", errUnclosedBrace, param)
+			}
+			value, err := evaluate(envs, param[i+2:end])
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(value)
+			i = end
+		default:
+			output.WriteByte(param[i])
+		}
 	}
-	submatches := envPattern.FindAllStringSubmatch(param, -1)
-	for _, match := range submatches {
-		key := match[0][2 : len(match[0])-1]
-		val, ok := envs[key]
-		if !ok {
-			val = os.Getenv(key)
-			if val == "" {
-				return "", errMissingEnv
+	return output.String(), nil
+}
+
+// closingBrace returns the index of the brace that closes the expression
+// that starts at start, or -1.
+func closingBrace(param string, start int) int {
+	depth := 1
+	for i := start; i < len(param); i++ {
+		switch {
+		case strings.HasPrefix(param[i:], "${"):
+			depth++
+			i++
+		case param[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
 			}
 		}
-		envPatternWithKey := regexp.MustCompile(`(\$\{` + key + `\})`)
-		param = envPatternWithKey.ReplaceAllString(param, val)
 	}
-	return param, nil
+	return -1
 }
 
-// toMap returns a map of values built with EnvVar and detect when there
-// is a duplicate key in the list
-func (evs envVars) toMap() (map[string]string, error) {
+// evaluate returns the value of the expression inside ${}.
+func evaluate(envs map[string]string, expression string) (string, error) {
+	if i := strings.Index(expression, "("); i > 0 && strings.HasSuffix(expression, ")") &&
+		!strings.Contains(expression[:i], ":") {
+		f, ok := envFuncs[expression[:i]]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", errUnknownFunc, expression[:i])
+		}
+		value, err := evaluate(envs, expression[i+1:len(expression)-1])
+		if err != nil {
+			return "", err
+		}
+		return f(value), nil
+	}
+	name, operator, argument := expression, "", ""
+	if i := strings.Index(expression, ":"); i >= 0 && i+1 < len(expression) &&
+		(expression[i+1] == '-' || expression[i+1] == '?') {
+		name, operator, argument = expression[:i], expression[i:i+2], expression[i+2:]
+	}
+	value, ok := envs[name]
+	if !ok {
+		value, ok = os.LookupEnv(name)
+	}
+	switch {
+	case operator == ":-" && value == "":
+		return expand(envs, argument)
+	case operator == ":?" && value == "":
+		message, err := expand(envs, argument)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %s: %s", errMissingEnv, name, message)
+	case !ok:
+		return "", fmt.Errorf("%w: %s", errMissingEnv, name)
+	}
+	return value, nil
+}
+
+// toMap returns a map of values built with EnvVar, a variable replaces the
+// variables with the same name defined before it.
+func (evs envVars) toMap() map[string]string {
 	keys := map[string]string{}
 	for _, v := range evs {
-		if _, ok := keys[v.Name]; ok {
-			return map[string]string{}, errDuplicateKeys
-		}
 		keys[v.Name] = v.Value
 	}
-	return keys, nil
+	return keys
+}
+
+// merge returns the variables updated with others: a variable of others
+// replaces the variable with the same name.
+func (evs envVars) merge(others ...envVar) envVars {
+	output := newEnvVars(evs...)
+	for _, v := range others {
+		found := false
+		for k := range output {
+			if output[k].Name == v.Name {
+				output[k].Value = v.Value
+				found = true
+			}
+		}
+		if !found {
+			output.add(v)
+		}
+	}
+	return output
+}
+
+// readEnvFile reads a dotenv file. Lines are KEY=VALUE and may start with
+// export; values in double quotes or without quotes are expanded with envs
+// and the variables defined above them, values in single quotes are kept as
+// is.
+func readEnvFile(filename string, envs envVars) (envVars, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	output := envVars{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+		i := strings.Index(text, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: %s:%d", errInvalidEnv, filename, line)
+		}
+		name, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			output = output.merge(envVar{Name: name, Value: value[1 : len(value)-1]})
+			continue
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		vars := envs.merge(output...)
+		if value, err = vars.replace(value); err != nil {
+			return nil, fmt.Errorf("%w: %s:%d", err, filename, line)
+		}
+		output = output.merge(envVar{Name: name, Value: value})
+	}
+	return output, scanner.Err()
 }
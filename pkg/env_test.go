@@ -1,6 +1,10 @@
 package pkg
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
 	"testing"
 )
 
@@ -41,49 +45,129 @@ func Test_replaceEnv_and_failure(t *testing.T) {
 	envs := newEnvVars(envVar{Name: "x", Value: "abc"})
 
 	for _, v := range input {
-		if _, err := envs.replace(v); err != errMissingEnv {
-			t.Error("we should fail and we are not")
+		_, err := envs.replace(v)
+		if !errors.Is(err, errMissingEnv) || err.Error() != "missing: version" {
+			t.Error("we should fail and we are not, error:", err)
 		}
 	}
 }
 
-func Test_replaceEnv_duplicate_key(t *testing.T) {
+func Test_replaceEnv_later_key_overrides(t *testing.T) {
 	envs := newEnvVars(envVar{Name: "VERSION", Value: "1.0"}, envVar{Name: "VERSION", Value: "2.0"})
+	output, err := envs.replace("v${VERSION}")
+	if err != nil || output != "v2.0" {
+		t.Error("should use the last definition, current:", output, err)
+	}
+}
+
+func Test_replaceEnv_expressions(t *testing.T) {
+	envs := newEnvVars(
+		envVar{Name: "version", Value: "0123456789abcdef"},
+		envVar{Name: "branch", Value: "Feature-X"},
+		envVar{Name: "empty", Value: ""},
+		envVar{Name: "fallback", Value: "fb"},
+	)
+	data := []struct {
+		input  string
+		output string
+	}{
+		{input: "${missing:-default}", output: "default"},
+		{input: "${empty:-default}", output: "default"},
+		{input: "${branch:-default}", output: "Feature-X"},
+		{input: "${missing:-${fallback}-1}", output: "fb-1"},
+		{input: "${missing:-${other:-x}}", output: "x"},
+		{input: "${empty}", output: ""},
+		{input: "${short(version)}", output: "0123456"},
+		{input: "${lower(branch)}", output: "feature-x"},
+		{input: "${upper(missing:-main)}", output: "MAIN"},
+		{input: "app-${lower(branch)}-${short(version)}", output: "app-feature-x-0123456"},
+		{input: "$${version}", output: "${version}"},
+		{input: "$$${version}", output: "$${version}"},
+		{input: "$HOME ${version:?no version}", output: "$HOME 0123456789abcdef"},
+	}
+	for _, v := range data {
+		output, err := envs.replace(v.input)
+		if err != nil || output != v.output {
+			t.Errorf("%s should return %q, current: %q, error: %v", v.input, v.output, output, err)
+		}
+	}
+}
 
-	if _, err := envs.replace("abc"); err != errDuplicateKeys {
-		t.Error("should fail with errDuplicateKeys, error:", err)
+func Test_replaceEnv_expressions_and_fail(t *testing.T) {
+	envs := newEnvVars(envVar{Name: "version", Value: "1"})
+	data := []struct {
+		input string
+		err   error
+		msg   string
+	}{
+		{input: "${token:?set the token in the secrets}", err: errMissingEnv, msg: "missing: token: set the token in the secrets"},
+		{input: "${missing:-${other}}", err: errMissingEnv, msg: "missing: other"},
+		{input: "${trim(version)}", err: errUnknownFunc, msg: "unknownfunc: trim"},
+		{input: "${version", err: errUnclosedBrace, msg: "unclosedbrace: ${version"},
+	}
+	for _, v := range data {
+		_, err := envs.replace(v.input)
+		if !errors.Is(err, v.err) || err.Error() != v.msg {
+			t.Errorf("%s should fail with %q, error: %v", v.input, v.msg, err)
+		}
 	}
 }
 
 func Test_groupEnvs_and_succeed(t *testing.T) {
+	input := envVars{{Name: "VERSION", Value: "1.0"}, {Name: "PORT", Value: "8080"}, {Name: "PORT", Value: "8081"}}
+	mapOfEnvs := input.toMap()
+	if len(mapOfEnvs) != 2 || mapOfEnvs["VERSION"] != "1.0" {
+		t.Error("We should have a version, current:", mapOfEnvs)
+	}
+	if mapOfEnvs["PORT"] != "8081" {
+		t.Errorf("port should be 8081, it is %s", mapOfEnvs["PORT"])
+	}
+}
+
+func Test_merge(t *testing.T) {
 	input := envVars{{Name: "VERSION", Value: "1.0"}, {Name: "PORT", Value: "8080"}}
-	mapOfEnvs, err := input.toMap()
+	output := input.merge(envVar{Name: "PORT", Value: "8081"}, envVar{Name: "HOST", Value: "localhost"})
+	if len(output) != 3 || output.get("PORT") != "8081" || output.get("HOST") != "localhost" {
+		t.Error("merge should override and add variables, current:", output)
+	}
+	if input.get("PORT") != "8080" {
+		t.Error("merge should not change the input")
+	}
+}
+
+func Test_readEnvFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
 	if err != nil {
-		t.Error("groupEnvs should succeed")
+		t.Error("could not create tmpdir")
+		t.FailNow()
 	}
-	version, ok := mapOfEnvs["VERSION"]
-	if !ok {
-		t.Error("We should have a version")
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, ".env")
+	os.WriteFile(filename, []byte(`# settings
+export HOST=localhost
+URL=http://${HOST}:${port} # the url
+QUOTED="v${version}\n"
+RAW='${version}'
+`), 0644)
+	envs, err := readEnvFile(filename, envVars{{Name: "port", Value: "8080"}, {Name: "version", Value: "1"}})
+	if err != nil {
+		t.Error("should succeed, error:", err)
 	}
-	if version != "1.0" {
-		t.Errorf("version should be 1.0, it is %s", version)
+	expected := envVars{
+		{Name: "HOST", Value: "localhost"},
+		{Name: "URL", Value: "http://localhost:8080"},
+		{Name: "QUOTED", Value: "v1\n"},
+		{Name: "RAW", Value: "${version}"},
 	}
-	port, ok := mapOfEnvs["PORT"]
-	if !ok {
-		t.Error("We should have a version")
+	if fmt.Sprint(envs) != fmt.Sprint(expected) {
+		t.Error("should read the env file, current:", envs)
 	}
-	if port != "8080" {
-		t.Errorf("port should be 8080, it is %s", port)
+	os.WriteFile(filename, []byte("HOST\n"), 0644)
+	if _, err := readEnvFile(filename, envVars{}); !errors.Is(err, errInvalidEnv) {
+		t.Error("should fail with errInvalidEnv, error:", err)
 	}
-}
-
-func Test_groupEnvs_and_fail(t *testing.T) {
-	input := envVars{{Name: "VERSION", Value: "1.0"}, {Name: "VERSION", Value: "2.0"}}
-	mapOfEnvs, err := input.toMap()
-	if err != errDuplicateKeys {
-		t.Error("groupEnvs should return ErrDuplicateKeys")
-	}
-	if len(mapOfEnvs) != 0 {
-		t.Error("mapOfEnvs should be empty")
+	os.WriteFile(filename, []byte("URL=${missing}\n"), 0644)
+	if _, err := readEnvFile(filename, envVars{}); !errors.Is(err, errMissingEnv) {
+		t.Error("should fail with errMissingEnv, error:", err)
 	}
 }
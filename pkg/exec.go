@@ -5,6 +5,8 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
+	"strings"
 
 	"github.com/go-logr/logr"
 )
@@ -12,14 +14,30 @@ import (
 type execStruct struct {
 	log     logr.Logger
 	name    string
+	runtime string
+	secrets *secrets
 	Command string   `json:"command"`
 	Args    []string `json:"args,omitempty"`
 	WorkDir string   `json:"workdir"`
 	Envs    envVars  `json:"envs,omitempty"`
 	Output  string   `json:"output,omitempty"`
-	files   []*file
+	Image   string   `json:"image,omitempty"`
+	EnvFile string   `json:"env_file,omitempty" yaml:"env_file"`
+	// Branches overrides the env file and the variables of the step for
+	// the versions built from a branch.
+	Branches map[string]branchStruct `json:"-" yaml:"branches"`
+	files    []*file
 }
 
+type branchStruct struct {
+	EnvFile string  `yaml:"env_file"`
+	Envs    envVars `yaml:"envs"`
+}
+
+const defaultRuntime = "docker"
+
+var nonContainerChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
 func getCmd(dir string, envs envVars, name string, args ...string) *exec.Cmd {
 	c := exec.Command(name, args...)
 	c.Dir = dir
@@ -34,11 +52,16 @@ func getCmd(dir string, envs envVars, name string, args ...string) *exec.Cmd {
 }
 
 func (e *execStruct) prepare(workspace string, envs envVars) (*exec.Cmd, error) {
-	dir := path.Join(workspace, e.WorkDir)
+	envs = e.secrets.inject(envs)
+	workdir, err := envs.replace(e.WorkDir)
+	if err != nil {
+		return nil, e.stepError(err)
+	}
+	dir := path.Join(workspace, workdir)
 	e.WorkDir = dir
 	command, err := envs.replace(e.Command)
 	if err != nil {
-		return nil, err
+		return nil, e.stepError(err)
 	}
 	e.Command = command
 	args := []string{}
@@ -46,7 +69,7 @@ func (e *execStruct) prepare(workspace string, envs envVars) (*exec.Cmd, error)
 	for _, arg := range e.Args {
 		arg, err = envs.replace(arg)
 		if err != nil {
-			return nil, err
+			return nil, e.stepError(err)
 		}
 		args = append(args, arg)
 		full = fmt.Sprintf("%s %s", full, arg)
@@ -55,10 +78,98 @@ func (e *execStruct) prepare(workspace string, envs envVars) (*exec.Cmd, error)
 	for index, value := range e.Envs {
 		if v := envs.get(value.Name); v == "" {
 			if e.Envs[index].Value, err = envs.replace(value.Value); err != nil {
+				return nil, e.stepError(err)
+			}
+		}
+	}
+	if e.Envs, err = e.environment(dir, envs); err != nil {
+		return nil, e.stepError(err)
+	}
+	if e.Image != "" {
+		command, args = e.containerize(dir, envs, command, args)
+		full = fmt.Sprintf("%s %s", command, strings.Join(args, " "))
+	}
+	e.log.Info(e.secrets.mask(full))
+	return getCmd(dir, e.secrets.inject(e.Envs), command, args...), nil
+}
+
+// stepError names the step in the error of a variable expansion.
+func (e *execStruct) stepError(err error) error {
+	if e.name == "" {
+		return err
+	}
+	return fmt.Errorf("step %s: %w", e.name, err)
+}
+
+// environment returns the variables of the step: the env file first, then
+// the variables of the step and the overrides of the branch. The env file
+// path is relative to the working directory of the step.
+func (e *execStruct) environment(dir string, envs envVars) (envVars, error) {
+	envFile, overrides := e.EnvFile, envVars{}
+	if b, ok := e.Branches[envs.get("branch")]; ok {
+		if b.EnvFile != "" {
+			envFile = b.EnvFile
+		}
+		for _, v := range b.Envs {
+			value, err := envs.replace(v.Value)
+			if err != nil {
 				return nil, err
 			}
+			overrides.addOne(v.Name, value)
 		}
 	}
-	e.log.Info(full)
-	return getCmd(dir, e.Envs, command, args...), nil
+	output := envVars{}
+	if envFile != "" {
+		filename, err := envs.replace(envFile)
+		if err != nil {
+			return nil, err
+		}
+		if !path.IsAbs(filename) {
+			filename = path.Join(dir, filename)
+		}
+		if output, err = readEnvFile(filename, envs); err != nil {
+			return nil, err
+		}
+	}
+	return output.merge(e.Envs...).merge(overrides...), nil
+}
+
+func (e *execStruct) getRuntime() string {
+	if e.runtime == "" {
+		return defaultRuntime
+	}
+	return e.runtime
+}
+
+// containerName returns a name that identifies the container of a step for
+// a version so that it can be removed when the process is killed. The port is
+// part of the name so that a version can be released again while it is live.
+func (e *execStruct) containerName(envs envVars) string {
+	name := fmt.Sprintf("crzy-%s-%s", e.name, envs.get("version"))
+	if port := envs.get("port"); port != "" {
+		name = fmt.Sprintf("%s-%s", name, port)
+	}
+	return nonContainerChars.ReplaceAllString(name, "_")
+}
+
+// containerize wraps the command in a run of the container runtime. The
+// workspace and the artifact directory are mounted on the same path so that
+// the variables remain valid inside the container; the port, when it exists,
+// is published on the same port. The variables are passed by name only so
+// that their values, e.g. secrets, do not appear in the arguments; the
+// runtime reads them from its own environment.
+func (e *execStruct) containerize(dir string, envs envVars, command string, args []string) (string, []string) {
+	output := []string{"run", "--rm", "--name", e.containerName(envs), "-v", dir + ":" + dir, "-w", dir}
+	if artifactDirectory := envs.get("artifactDirectory"); artifactDirectory != "" &&
+		artifactDirectory != dir {
+		output = append(output, "-v", artifactDirectory+":"+artifactDirectory)
+	}
+	if port := envs.get("port"); port != "" {
+		output = append(output, "-p", port+":"+port)
+	}
+	for _, v := range e.secrets.inject(e.Envs) {
+		output = append(output, "-e", v.Name)
+	}
+	output = append(output, e.Image, command)
+	return e.getRuntime(), append(output, args...)
 }
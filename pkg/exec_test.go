@@ -1,7 +1,11 @@
 package pkg
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"os/exec"
+	"path"
 	"runtime"
 	"testing"
 
@@ -57,7 +61,7 @@ func Test_prepare_and_fail_command(t *testing.T) {
 		Envs:    envVars{},
 	}
 	_, err := e.prepare(".", envVars{})
-	if err != errMissingEnv {
+	if !errors.Is(err, errMissingEnv) {
 		t.Error(err, "should fail")
 	}
 }
@@ -71,7 +75,7 @@ func Test_prepare_and_fail_args(t *testing.T) {
 		Envs:    envVars{},
 	}
 	_, err := e.prepare(".", envVars{})
-	if err != errMissingEnv {
+	if !errors.Is(err, errMissingEnv) {
 		t.Error(err, "should fail")
 	}
 }
@@ -85,7 +89,127 @@ func Test_prepare_and_fail_envs(t *testing.T) {
 		Envs:    envVars{{Name: "xxx", Value: "${xxx}"}},
 	}
 	_, err := e.prepare(".", envVars{})
-	if err != errMissingEnv {
+	if !errors.Is(err, errMissingEnv) {
 		t.Error(err, "should fail")
 	}
 }
+
+func Test_prepare_and_name_the_step(t *testing.T) {
+	e := &execStruct{
+		log:     &log.MockLogger{},
+		name:    "build",
+		Command: "go",
+		Args:    []string{"build", "-o", "${artifact}"},
+	}
+	_, err := e.prepare(".", envVars{})
+	if !errors.Is(err, errMissingEnv) || err.Error() != "step build: missing: artifact" {
+		t.Error("should fail naming the step and the variable, error:", err)
+	}
+}
+
+func Test_prepare_with_image(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake runtime is a shell script")
+	}
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	fake := path.Join(dir, "fake-runtime")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\necho \"$@\" \"$PORT\"\n"), 0755); err != nil {
+		t.Error("could not create the fake runtime", err)
+		t.FailNow()
+	}
+	w := &workflow{
+		log:     &log.MockLogger{},
+		version: "abc",
+		name:    "release",
+		basedir: dir,
+		envs: envVars{
+			{Name: "version", Value: "abc"},
+			{Name: "port", Value: "8090"},
+		},
+		state: &stateMockClient{},
+	}
+	e := &execStruct{
+		log:     &log.MockLogger{},
+		name:    "run",
+		runtime: fake,
+		Command: "./go-${version}",
+		WorkDir: ".",
+		Envs:    envVars{{Name: "PORT", Value: ":${port}"}},
+		Image:   "golang:1.16",
+		Output:  "args",
+	}
+	output, err := w.execute(e)
+	if err != nil {
+		t.Error("execute should succeed", err)
+		t.FailNow()
+	}
+	expected := fmt.Sprintf("run --rm --name crzy-run-abc-8090 -v %s:%s -w %s -p 8090:8090 -e PORT golang:1.16 ./go-abc :8090", dir, dir, dir)
+	if output.Value != expected {
+		t.Errorf("expected %q, current: %q", expected, output.Value)
+	}
+}
+
+func Test_getRuntime(t *testing.T) {
+	e := &execStruct{}
+	if e.getRuntime() != defaultRuntime {
+		t.Error("should default to docker")
+	}
+	e.runtime = "podman"
+	if e.getRuntime() != "podman" {
+		t.Error("should return podman")
+	}
+	e.name = "run"
+	if name := e.containerName(envVars{{Name: "version", Value: "feat/1"}}); name != "crzy-run-feat_1" {
+		t.Error("should sanitize the container name, current:", name)
+	}
+	if name := e.containerName(envVars{{Name: "version", Value: "1"}, {Name: "port", Value: "8090"}}); name != "crzy-run-1-8090" {
+		t.Error("should add the port to the container name, current:", name)
+	}
+}
+
+func Test_prepare_with_env_file_and_branch(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.WriteFile(path.Join(dir, ".env"), []byte("DB=main\nLEVEL=info\n"), 0644)
+	os.WriteFile(path.Join(dir, ".env.dev"), []byte("DB=dev\nLEVEL=debug\n"), 0644)
+	data := []struct {
+		branch string
+		db     string
+		level  string
+		debug  string
+	}{
+		{branch: "main", db: "main", level: "warn"},
+		{branch: "dev", db: "dev", level: "warn", debug: "dev-1"},
+	}
+	for _, v := range data {
+		e := &execStruct{
+			log:     &log.MockLogger{},
+			Command: "env",
+			EnvFile: ".env",
+			Envs:    envVars{{Name: "LEVEL", Value: "warn"}},
+			Branches: map[string]branchStruct{
+				"dev": {EnvFile: ".env.${branch}", Envs: envVars{{Name: "DEBUG", Value: "${branch}-${version}"}}},
+			},
+		}
+		_, err := e.prepare(dir, envVars{{Name: "branch", Value: v.branch}, {Name: "version", Value: "1"}})
+		if err != nil {
+			t.Error(v.branch, "should succeed, error:", err)
+		}
+		if e.Envs.get("DB") != v.db || e.Envs.get("LEVEL") != v.level || e.Envs.get("DEBUG") != v.debug {
+			t.Error(v.branch, "should compute the environment, current:", e.Envs)
+		}
+	}
+	e := &execStruct{log: &log.MockLogger{}, Command: "env", EnvFile: "missing.env"}
+	if _, err := e.prepare(dir, envVars{}); err == nil {
+		t.Error("should fail with a missing env file")
+	}
+}
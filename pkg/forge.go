@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	githubForge         = "github"
+	gitlabForge         = "gitlab"
+	giteaForge          = "gitea"
+	defaultForgeContext = "crzy"
+	forgeDescriptionMax = 140
+
+	statusPending = "pending"
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+var (
+	errInvalidForge = errors.New("invalidforge")
+	errForgeFailed  = errors.New("forgefailed")
+
+	// forgeURLs are the default APIs of the hosted forges.
+	forgeURLs = map[string]string{
+		githubForge: "https://api.github.com",
+		gitlabForge: "https://gitlab.com/api/v4",
+	}
+
+	// forgeStatuses are the commit statuses reported for the events. The
+	// pipeline only succeeds once the release is verified and switched, or
+	// once the deploy succeeds when there is no release.
+	forgeStatuses = map[string]string{
+		eventPushReceived:    statusPending,
+		eventStepFailed:      statusFailure,
+		eventReleaseSwitched: statusSuccess,
+		eventReleaseFailed:   statusFailure,
+	}
+)
+
+// forgeStruct configures the commit statuses reported to GitHub, GitLab or
+// Gitea. The url is the API of the forge, it defaults to github.com and
+// gitlab.com. The repository is owner/name, or the path of the project with
+// GitLab. The token can reference the secrets with ${}.
+type forgeStruct struct {
+	Provider   string `yaml:"provider"`
+	URL        string `yaml:"url"`
+	Token      string `yaml:"token"`
+	Repository string `yaml:"repository"`
+	Context    string `yaml:"context"`
+}
+
+// forgeNotifier reports the status of the pipeline of a version on its
+// commit with a link to the dashboard. deployOnly is set when no release
+// is configured so that the pipeline ends with the deploy.
+type forgeNotifier struct {
+	provider   string
+	url        string
+	token      string
+	repository string
+	context    string
+	dashboard  string
+	deployOnly bool
+	client     *http.Client
+}
+
+// newForgeNotifier returns nil when no provider is configured.
+func newForgeNotifier(conf forgeStruct, dashboard string, s *secrets) (*forgeNotifier, error) {
+	if conf.Provider == "" {
+		return nil, nil
+	}
+	address := conf.URL
+	if address == "" {
+		address = forgeURLs[conf.Provider]
+	}
+	switch conf.Provider {
+	case githubForge, gitlabForge, giteaForge:
+	default:
+		return nil, errInvalidForge
+	}
+	if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errInvalidForge
+	}
+	if conf.Repository == "" || (conf.Provider != gitlabForge && len(strings.Split(conf.Repository, "/")) != 2) {
+		return nil, errInvalidForge
+	}
+	envs := s.envs()
+	token, err := envs.replace(conf.Token)
+	if err != nil {
+		return nil, err
+	}
+	context := conf.Context
+	if context == "" {
+		context = defaultForgeContext
+	}
+	return &forgeNotifier{
+		provider:   conf.Provider,
+		url:        strings.TrimRight(address, "/"),
+		token:      token,
+		repository: conf.Repository,
+		context:    context,
+		dashboard:  dashboard,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (f *forgeNotifier) name() string {
+	return f.provider
+}
+
+// status returns the commit status of an event and whether it is reported.
+func (f *forgeNotifier) status(event string) (string, bool) {
+	if event == eventDeploySucceeded && f.deployOnly {
+		return statusSuccess, true
+	}
+	status, ok := forgeStatuses[event]
+	return status, ok
+}
+
+func (f *forgeNotifier) accept(event string) bool {
+	_, ok := f.status(event)
+	return ok
+}
+
+// request creates the call to the commit status API of the forge.
+func (f *forgeNotifier) request(n notification) (*http.Request, error) {
+	status, _ := f.status(n.Event)
+	description := n.Message
+	if len(description) > forgeDescriptionMax {
+		description = description[:forgeDescriptionMax-3] + "..."
+	}
+	target := ""
+	if f.dashboard != "" {
+		target = f.dashboard + dashboardPath + "#" + url.PathEscape(n.Version)
+	}
+	payload := map[string]string{
+		"state":       status,
+		"target_url":  target,
+		"description": description,
+		"context":     f.context,
+	}
+	address := fmt.Sprintf("%s/repos/%s/statuses/%s", f.url, f.repository, n.SHA)
+	if f.provider == gitlabForge {
+		if status == statusFailure {
+			payload["state"] = "failed"
+		}
+		payload["name"] = f.context
+		delete(payload, "context")
+		address = fmt.Sprintf("%s/projects/%s/statuses/%s", f.url, url.PathEscape(f.repository), n.SHA)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	switch f.provider {
+	case githubForge:
+		request.Header.Set("Accept", "application/vnd.github+json")
+		request.Header.Set("Authorization", "Bearer "+f.token)
+	case gitlabForge:
+		request.Header.Set("PRIVATE-TOKEN", f.token)
+	case giteaForge:
+		request.Header.Set("Authorization", "token "+f.token)
+	}
+	return request, nil
+}
+
+// notify reports the status of the commit of the notification.
+func (f *forgeNotifier) notify(n notification) error {
+	if !f.accept(n.Event) || n.SHA == "" {
+		return nil
+	}
+	request, err := f.request(n)
+	if err != nil {
+		return err
+	}
+	response, err := f.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", errForgeFailed, response.Status)
+	}
+	return nil
+}
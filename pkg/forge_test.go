@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+// forgePayload decodes the status posted to the forge.
+func forgePayload(r testRequest) map[string]string {
+	payload := map[string]string{}
+	json.Unmarshal(r.body, &payload)
+	return payload
+}
+
+func Test_forgeNotifier(t *testing.T) {
+	server, requests := newTestServer("", http.StatusCreated)
+	defer server.Close()
+	s := &secrets{values: envVars{{Name: "FORGE_TOKEN", Value: "s3cr3t"}}}
+	data := []struct {
+		provider   string
+		repository string
+		event      string
+		path       string
+		header     string
+		value      string
+		state      string
+		context    string
+	}{
+		{provider: githubForge, repository: "go-crzy/crzy", event: eventPushReceived, path: "/repos/go-crzy/crzy/statuses/abc123", header: "Authorization", value: "Bearer s3cr3t", state: "pending", context: "context"},
+		{provider: giteaForge, repository: "go-crzy/crzy", event: eventReleaseSwitched, path: "/repos/go-crzy/crzy/statuses/abc123", header: "Authorization", value: "token s3cr3t", state: "success", context: "context"},
+		{provider: gitlabForge, repository: "go-crzy/crzy", event: eventStepFailed, path: "/projects/go-crzy%2Fcrzy/statuses/abc123", header: "Private-Token", value: "s3cr3t", state: "failed", context: "name"},
+	}
+	for _, v := range data {
+		f, err := newForgeNotifier(forgeStruct{Provider: v.provider, URL: server.URL, Token: "${FORGE_TOKEN}", Repository: v.repository}, "http://localhost:8080", s)
+		if err != nil {
+			t.Error(v.provider, "should succeed, error:", err)
+			t.FailNow()
+		}
+		if err := f.notify(notification{Event: v.event, Version: "v1", SHA: "abc123", Message: "version v1"}); err != nil {
+			t.Error(v.provider, "should succeed, error:", err)
+		}
+		r := <-requests
+		payload := forgePayload(r)
+		if r.path != v.path || r.header.Get(v.header) != v.value {
+			t.Error(v.provider, "should call the status API, current:", r.path, r.header)
+		}
+		if payload["state"] != v.state || payload[v.context] != defaultForgeContext ||
+			payload["target_url"] != "http://localhost:8080/ui/#v1" || payload["description"] != "version v1" {
+			t.Error(v.provider, "should report the status, current:", payload)
+		}
+	}
+}
+
+func Test_forgeNotifier_events(t *testing.T) {
+	server, requests := newTestServer("", http.StatusUnauthorized)
+	defer server.Close()
+	f, err := newForgeNotifier(forgeStruct{Provider: githubForge, URL: server.URL, Repository: "go-crzy/crzy", Context: "crzy/test"}, "", nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if f.accept(eventDeploySucceeded) || f.accept(eventRollback) {
+		t.Error("should only accept the events of the pipeline")
+	}
+	if err := f.notify(notification{Event: eventPushReceived, Version: "v1"}); err != nil || len(requests) != 0 {
+		t.Error("should ignore the notification without commit, error:", err)
+	}
+	err = f.notify(notification{Event: eventReleaseFailed, Version: "v1", SHA: "abc123"})
+	if !errors.Is(err, errForgeFailed) {
+		t.Error("should fail with errForgeFailed, error:", err)
+	}
+	payload := forgePayload(<-requests)
+	if payload["context"] != "crzy/test" || payload["state"] != "failure" || payload["target_url"] != "" {
+		t.Error("should report the failure, current:", payload)
+	}
+	f.deployOnly = true
+	if !f.accept(eventDeploySucceeded) {
+		t.Error("should accept the deploy without release")
+	}
+	f.notify(notification{Event: eventDeploySucceeded, Version: "v1", SHA: "abc123"})
+	if payload := forgePayload(<-requests); payload["state"] != "success" {
+		t.Error("should report the success of the deploy, current:", payload)
+	}
+}
+
+func Test_newForgeNotifier(t *testing.T) {
+	f, err := newForgeNotifier(forgeStruct{}, "", nil)
+	if err != nil || f != nil {
+		t.Error("should be disabled without provider", err)
+	}
+	f, err = newForgeNotifier(forgeStruct{Provider: gitlabForge, Repository: "12"}, "", nil)
+	if err != nil || f.url != "https://gitlab.com/api/v4" {
+		t.Error("should default to gitlab.com", err)
+	}
+	for _, v := range []forgeStruct{
+		{Provider: "bitbucket", Repository: "go-crzy/crzy"},
+		{Provider: giteaForge, Repository: "go-crzy/crzy"},
+		{Provider: githubForge, Repository: "crzy"},
+		{Provider: githubForge, URL: "ftp://example.com", Repository: "go-crzy/crzy"},
+	} {
+		if _, err := newForgeNotifier(v, "", nil); err != errInvalidForge {
+			t.Error("should fail with errInvalidForge, current:", v, err)
+		}
+	}
+}
+
+func Test_newNotifiers_without_release(t *testing.T) {
+	conf := &config{Notifier: notifierStruct{Forge: forgeStruct{Provider: githubForge, Repository: "go-crzy/crzy"}}}
+	n, err := newNotifiers(&log.MockLogger{}, conf, nil)
+	if err != nil || len(n.backends) != 2 {
+		t.Error("should create the forge, error:", err)
+		t.FailNow()
+	}
+	if f := n.backends[1].(*forgeNotifier); !f.deployOnly {
+		t.Error("should end the pipeline with the deploy")
+	}
+	conf.Release.Run.Command = "./go-${version}"
+	n, _ = newNotifiers(&log.MockLogger{}, conf, nil)
+	if f := n.backends[1].(*forgeNotifier); f.deployOnly {
+		t.Error("should end the pipeline with the release")
+	}
+}
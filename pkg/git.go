@@ -19,6 +19,9 @@ type gitCommand interface {
 	getWorkspace() string
 	getExecdir() string
 	syncWorkspace(string) error
+	addWorktree(name, sha string) (string, error)
+	removeWorktree(dir string) error
+	fetchRemote(url, branch string) (string, error)
 }
 
 type defaultGitCommand struct {
@@ -78,6 +81,48 @@ func (git *defaultGitCommand) syncWorkspace(head string) error {
 	return nil
 }
 
+// addWorktree checks out the sha in a dedicated worktree so that the version
+// is built from the exact commit it is labelled with, even if the workspace
+// moves forward in the meantime.
+func (git *defaultGitCommand) addWorktree(name, sha string) (string, error) {
+	dir := path.Join(git.store.treeDir, name)
+	if _, err := os.Stat(dir); err == nil {
+		if err := git.removeWorktree(dir); err != nil {
+			return "", err
+		}
+	}
+	if output, err := getCmd(git.store.workdir, envVars{}, git.bin, "worktree", "add", "--detach", dir, sha).CombinedOutput(); err != nil {
+		git.log.Error(err, "could not add worktree,", "data", string(output))
+		return "", err
+	}
+	return dir, nil
+}
+
+func (git *defaultGitCommand) removeWorktree(dir string) error {
+	if output, err := getCmd(git.store.workdir, envVars{}, git.bin, "worktree", "remove", "--force", dir).CombinedOutput(); err != nil {
+		git.log.Error(err, "could not remove worktree,", "data", string(output))
+		return err
+	}
+	return nil
+}
+
+// fetchRemote updates the branch of the repository from a remote, it
+// replaces the branch when the remote has been force pushed. It returns the
+// commit the branch is on once fetched.
+func (git *defaultGitCommand) fetchRemote(url, branch string) (string, error) {
+	ref := "refs/heads/" + branch
+	if output, err := getCmd(git.store.repoDir, envVars{}, git.bin, "fetch", "--quiet", url, "+"+ref+":"+ref).CombinedOutput(); err != nil {
+		git.log.Error(err, "could not fetch remote,", "data", string(output))
+		return "", err
+	}
+	output, err := getCmd(git.store.repoDir, envVars{}, git.bin, "rev-parse", "--verify", ref).CombinedOutput()
+	if err != nil {
+		git.log.Error(err, "could not resolve branch,", "data", string(output))
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func (git *defaultGitCommand) getBin() string {
 	return git.bin
 }
@@ -103,6 +148,11 @@ type gitServer struct {
 	release    chan<- event
 	log        logr.Logger
 	state      *stateManager
+	routes     []apiRoute
+	metrics    http.Handler
+	tracer     *tracer
+	remote     *remote
+	scripts    *scripts
 }
 
 func (r *defaultContainer) newGitServer(store store, state *stateManager, action chan<- event, release chan<- event) (*gitServer, error) {
@@ -131,6 +181,16 @@ func (r *defaultContainer) newGitServer(store store, state *stateManager, action
 	if err := command.initRepository(); err != nil {
 		return nil, err
 	}
+	remote, err := newRemote(r.config.Trigger.Remote, r.secrets)
+	if err != nil {
+		r.log.Error(err, "invalid remote")
+		return nil, err
+	}
+	scripts, err := newScripts(r.log.WithName("scripts"), r.config, r.secrets, command, state)
+	if err != nil {
+		r.log.Error(err, "invalid scripts")
+		return nil, err
+	}
 	server := &gitServer{
 		repoName:   r.config.Main.Repository,
 		head:       r.config.Main.Head,
@@ -139,6 +199,11 @@ func (r *defaultContainer) newGitServer(store store, state *stateManager, action
 		release:    release,
 		log:        log,
 		state:      state,
+		routes:     r.newRoutes(),
+		metrics:    r.getMetrics(),
+		tracer:     r.getTracer(),
+		remote:     remote,
+		scripts:    scripts,
 	}
 	handler := loggingMiddleware(r.log.WithName("git"), server.captureAndTrigger(ghx))
 	handler = r.config.authMiddleware(handler)
@@ -147,7 +212,21 @@ func (r *defaultContainer) newGitServer(store store, state *stateManager, action
 }
 
 func (g *gitServer) captureAndTrigger(next http.Handler) http.Handler {
-	mux := newAPI(g.state)
+	routes := append([]apiRoute{{
+		pattern: "/v0/actions",
+		handler: &actionHandler{state: g.state, release: g.release},
+	}, {
+		pattern: webhooksPath,
+		handler: &pushHandler{log: g.log, remote: g.remote, head: g.head, git: g.gitCommand, action: g.action, tracer: g.tracer},
+	}, {
+		pattern: scriptsPath,
+		handler: &scriptHandler{scripts: g.scripts},
+	}, {
+		pattern: scriptsPath + "/",
+		handler: &scriptHandler{scripts: g.scripts},
+	}}, g.routes...)
+	mux := newAPI(g.state, routes...)
+	dashboard := newDashboard()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		method := r.Method
@@ -155,6 +234,14 @@ func (g *gitServer) captureAndTrigger(next http.Handler) http.Handler {
 			mux.ServeHTTP(w, r)
 			return
 		}
+		if path == metricsPath && g.metrics != nil {
+			g.metrics.ServeHTTP(w, r)
+			return
+		}
+		if path == "/" || path+"/" == dashboardPath || strings.HasPrefix(path, dashboardPath) {
+			dashboard.ServeHTTP(w, r)
+			return
+		}
 		if len(path) >= len(g.repoName)+1 && path[:len(g.repoName)+1] == "/"+g.repoName {
 			r.URL.Path = path[len(g.repoName)+1:]
 		}
@@ -163,9 +250,15 @@ func (g *gitServer) captureAndTrigger(next http.Handler) http.Handler {
 			return
 		}
 		path = r.URL.Path
-		next.ServeHTTP(w, r)
-		if path == "/git-receive-pack" && method == http.MethodPost {
-			g.action <- event{id: triggeredMessage}
+		if path != "/git-receive-pack" || method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
 		}
+		parent, _ := parseTraceparent(r.Header.Get(traceparentHeader))
+		s := g.tracer.start(parent, "git.receive", spanKindServer)
+		s.setAttribute("crzy.repository", g.repoName)
+		next.ServeHTTP(w, r)
+		s.finish(nil)
+		g.action <- event{id: triggeredMessage, trace: s.spanContext()}
 	})
 }
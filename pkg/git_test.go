@@ -44,6 +44,18 @@ func (git *mockGitSuccessCommand) syncWorkspace(head string) error {
 	return nil
 }
 
+func (git *mockGitSuccessCommand) addWorktree(name, sha string) (string, error) {
+	return "/worktrees/" + name, nil
+}
+
+func (git *mockGitSuccessCommand) removeWorktree(dir string) error {
+	return nil
+}
+
+func (git *mockGitSuccessCommand) fetchRemote(url, branch string) (string, error) {
+	return "0123456789abcdef", nil
+}
+
 type mockGitFailCommand struct {
 }
 
@@ -75,6 +87,18 @@ func (git *mockGitFailCommand) syncWorkspace(head string) error {
 	return errors.New("error")
 }
 
+func (git *mockGitFailCommand) addWorktree(name, sha string) (string, error) {
+	return "", errors.New("error")
+}
+
+func (git *mockGitFailCommand) removeWorktree(dir string) error {
+	return errors.New("error")
+}
+
+func (git *mockGitFailCommand) fetchRemote(url, branch string) (string, error) {
+	return "", errors.New("error")
+}
+
 func Test_newDefaultGitCommand(t *testing.T) {
 	store := store{
 		rootDir: "/root",
@@ -136,6 +160,12 @@ func Test_newGitServer(t *testing.T) {
 	}
 	action := make(chan event)
 	release := make(chan event)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Error("could not get working directory")
+		t.FailNow()
+	}
+	defer os.Chdir(wd)
 	_, err = r.newGitServer(store, &stateManager{}, action, release)
 	if err != nil {
 		t.Error("should succeed", err)
@@ -317,3 +347,97 @@ func Test_captureAndTrigger_and_skip(t *testing.T) {
 		)
 	}
 }
+
+func Test_addWorktree_and_removeWorktree(t *testing.T) {
+	tmpdir, err := os.MkdirTemp("", "crzytest")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpdir)
+	workdir := path.Join(tmpdir, "workspace")
+	os.Mkdir(workdir, os.ModeDir|os.ModePerm)
+	for _, v := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=crzy", "-c", "user.email=crzy@localhost", "commit", "-q", "--allow-empty", "-m", "first"},
+	} {
+		if output, err := getCmd(workdir, envVars{}, "git", v...).CombinedOutput(); err != nil {
+			t.Error("could not initialize the repository", string(output))
+			t.FailNow()
+		}
+	}
+	output, _ := getCmd(workdir, envVars{}, "git", "rev-parse", "HEAD").CombinedOutput()
+	sha := strings.TrimSpace(string(output))
+	g := &defaultGitCommand{
+		bin: "git",
+		store: store{
+			workdir: workdir,
+			treeDir: path.Join(tmpdir, "worktrees"),
+		},
+		log: &log.MockLogger{},
+	}
+	dir, err := g.addWorktree("abc", sha)
+	if err != nil || dir != path.Join(tmpdir, "worktrees", "abc") {
+		t.Error("should create the worktree", dir, err)
+		t.FailNow()
+	}
+	if _, err := g.addWorktree("abc", sha); err != nil {
+		t.Error("should replace an existing worktree", err)
+	}
+	output, _ = getCmd(dir, envVars{}, "git", "rev-parse", "HEAD").CombinedOutput()
+	if strings.TrimSpace(string(output)) != sha {
+		t.Error("worktree should be on the commit, current:", string(output))
+	}
+	if err := g.removeWorktree(dir); err != nil {
+		t.Error("should remove the worktree", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("worktree should be deleted", err)
+	}
+	if _, err := g.addWorktree("def", "doesnotexist"); err == nil {
+		t.Error("should fail with an unknown commit")
+	}
+}
+
+func Test_fetchRemote(t *testing.T) {
+	tmpdir, err := os.MkdirTemp("", "crzytest")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpdir)
+	origin := path.Join(tmpdir, "origin")
+	repository := path.Join(tmpdir, "repository")
+	os.Mkdir(origin, os.ModeDir|os.ModePerm)
+	os.Mkdir(repository, os.ModeDir|os.ModePerm)
+	for _, v := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=crzy", "-c", "user.email=crzy@localhost", "commit", "-q", "--allow-empty", "-m", "first"},
+	} {
+		if output, err := getCmd(origin, envVars{}, "git", v...).CombinedOutput(); err != nil {
+			t.Error("could not initialize the repository", string(output))
+			t.FailNow()
+		}
+	}
+	output, _ := getCmd(origin, envVars{}, "git", "rev-parse", "HEAD").CombinedOutput()
+	sha := strings.TrimSpace(string(output))
+	g := &defaultGitCommand{
+		bin:   "git",
+		store: store{repoDir: repository},
+		log:   &log.MockLogger{},
+	}
+	if err := g.initRepository(); err != nil {
+		t.Error("could not initialize the bare repository", err)
+		t.FailNow()
+	}
+	if head, err := g.fetchRemote(origin, "main"); err != nil || head != sha {
+		t.Error("should fetch the branch and return its head", head, err)
+	}
+	output, _ = getCmd(repository, envVars{}, "git", "rev-parse", "refs/heads/main").CombinedOutput()
+	if strings.TrimSpace(string(output)) != sha {
+		t.Error("branch should be on the commit, current:", string(output))
+	}
+	if _, err := g.fetchRemote(origin, "unknown"); err == nil {
+		t.Error("should fail with an unknown branch")
+	}
+}
@@ -103,8 +103,10 @@ func checkCredentials(authorization, username, password string) error {
 	return nil
 }
 
+// ServeHTTP checks the credentials except for the webhooks of the forges that
+// are authenticated with their signature.
 func (ex *expl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if ex.password != nil && ex.username != nil &&
+	if ex.password != nil && ex.username != nil && !strings.HasPrefix(r.URL.Path, webhooksPath) &&
 		checkCredentials(r.Header.Get("authorization"), *ex.username, *ex.password) != nil {
 		w.Header().Add("WWW-Authenticate", "Basic realm=\"auth required\"")
 		w.WriteHeader(http.StatusUnauthorized)
@@ -179,6 +179,29 @@ func Test_AuthdHandler_authorized(t *testing.T) {
 	}
 }
 
+func Test_AuthdHandler_webhooks(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/v0/webhooks/github", nil)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("hello"))
+	})
+	username := "username"
+	password := "password"
+	conf := &config{Main: mainStruct{
+		API: apiStruct{Username: &username, Password: &password},
+	}}
+	next := conf.authMiddleware(handler)
+	next.ServeHTTP(recorder, request)
+	result := recorder.Result()
+	if result.StatusCode != http.StatusOK {
+		t.Errorf(
+			"Status Code should be 200, current: %d",
+			result.StatusCode,
+		)
+	}
+}
+
 func Test_corsMiddleware(t *testing.T) {
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest(http.MethodPost, "/", nil)
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errConfigExists = errors.New("configexists")
+
+// InitArgs holds the options of the init command.
+type InitArgs struct {
+	Dir    string
+	Lang   string
+	Remote bool
+	Force  bool
+	Out    io.Writer
+}
+
+// initComments documents the generated configuration. Keys are the path of
+// the YAML node.
+var initComments = map[string]string{
+	"main":               "main configures the GIT server, the API and the proxy",
+	"main.repository":    "name of the repository, push to http://localhost:<api port>/<repository>",
+	"main.head":          "branch that is built and deployed",
+	"main.api":           "the API, the dashboard and the GIT server share the same port",
+	"main.proxy":         "the proxy forwards the requests to the version that is live",
+	"deploy":             "deploy runs on every push: install, test, pre_build and build",
+	"deploy.artifact":    "artifact built by the deploy and started by the release",
+	"deploy.cache":       "reuse the install and the build when their inputs are unchanged",
+	"release":            "release starts the artifact and switches the proxy once it listens",
+	"release.run":        "${port} is the private port reserved for the version",
+	"release.port_range": "ports the versions can listen on",
+	"notifier":           "notifications are disabled unless the token is set",
+}
+
+// Init scaffolds a crzy.yaml in a directory from the template of the
+// detected language and, optionally, adds a crzy remote to its repository.
+// The remote is added first so that a failure leaves no configuration
+// behind.
+func Init(args InitArgs) error {
+	if args.Out == nil {
+		args.Out = os.Stdout
+	}
+	dir, err := filepath.Abs(args.Dir)
+	if err != nil {
+		return err
+	}
+	lang := args.Lang
+	if lang == "" {
+		lang = detectLanguage(dir)
+	}
+	if lang == "" {
+		lang = defaultLanguage
+	}
+	template, ok := languageTemplates[lang]
+	if !ok {
+		return errUnsupportedLang
+	}
+	filename := path.Join(dir, DefaultConfigFile)
+	if _, err := os.Stat(filename); err == nil && !args.Force {
+		return errConfigExists
+	}
+	content, err := langTemplate.ReadFile(template)
+	if err != nil {
+		return err
+	}
+	repository := filepath.Base(dir) + ".git"
+	output, err := commentConfig(content, lang, repository)
+	if err != nil {
+		return err
+	}
+	conf := &config{}
+	if args.Remote {
+		if err := yaml.Unmarshal(output, conf); err != nil {
+			return err
+		}
+		url := fmt.Sprintf("http://localhost:%d/%s", conf.Main.API.Port, repository)
+		if output, err := getCmd(dir, envVars{}, "git", "remote", "add", "crzy", url).CombinedOutput(); err != nil {
+			fmt.Fprintf(args.Out, "could not add the crzy remote: %s", string(output))
+			return err
+		}
+	}
+	if err := os.WriteFile(filename, output, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(args.Out, "%s created for a %s project\n", filename, lang)
+	if args.Remote {
+		fmt.Fprintf(args.Out, "remote crzy added, push with: git push crzy %s\n", conf.Main.Head)
+	}
+	return nil
+}
+
+// commentConfig sets the repository of the template and documents its
+// main keys.
+func commentConfig(content []byte, lang, repository string) ([]byte, error) {
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, errUnsupportedLang
+	}
+	document.HeadComment = fmt.Sprintf("crzy configuration for a %s project, generated by crzy init", lang)
+	annotate(document.Content[0], "", repository)
+	output := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+func annotate(node *yaml.Node, prefix, repository string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := strings.TrimPrefix(prefix+"."+key.Value, ".")
+		if comment, ok := initComments[name]; ok {
+			key.HeadComment = comment
+		}
+		if name == "main.repository" {
+			value.Value = repository
+		}
+		annotate(value, name, repository)
+	}
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Init_and_succeed(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.WriteFile(path.Join(dir, "Cargo.toml"), []byte{}, 0644)
+	if err := Init(InitArgs{Dir: dir, Out: io.Discard}); err != nil {
+		t.Error("init should succeed", err)
+		t.FailNow()
+	}
+	c, err := getConfig(rustLanguage, path.Join(dir, DefaultConfigFile))
+	if err != nil {
+		t.Error("configuration should load", err)
+		t.FailNow()
+	}
+	if c.Main.Repository != filepath.Base(dir)+".git" {
+		t.Error("repository should match the directory, current:", c.Main.Repository)
+	}
+	if c.Deploy.Test.Command != "cargo" {
+		t.Error("template should be rust, current:", c.Deploy.Test.Command)
+	}
+	content, _ := os.ReadFile(path.Join(dir, DefaultConfigFile))
+	if !strings.HasPrefix(string(content), "# crzy configuration for a rust project") ||
+		!strings.Contains(string(content), "# "+initComments["deploy"]) {
+		t.Error("configuration should be commented, current:", string(content))
+	}
+	if err := Init(InitArgs{Dir: dir, Out: io.Discard}); err != errConfigExists {
+		t.Error("should fail with errConfigExists, current:", err)
+	}
+	if err := Init(InitArgs{Dir: dir, Lang: "cobol", Force: true, Out: io.Discard}); err != errUnsupportedLang {
+		t.Error("should fail with errUnsupportedLang, current:", err)
+	}
+}
+
+func Test_Init_with_remote(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if err := Init(InitArgs{Dir: dir, Remote: true, Out: io.Discard}); err == nil {
+		t.Error("should fail without a repository")
+	}
+	if _, err := os.Stat(path.Join(dir, DefaultConfigFile)); !os.IsNotExist(err) {
+		t.Error("configuration should not be created", err)
+	}
+	if output, err := getCmd(dir, envVars{}, "git", "init", "-q").CombinedOutput(); err != nil {
+		t.Error("could not initialize the repository", string(output))
+		t.FailNow()
+	}
+	if err := Init(InitArgs{Dir: dir, Remote: true, Out: io.Discard}); err != nil {
+		t.Error("init should succeed", err)
+	}
+	output, _ := getCmd(dir, envVars{}, "git", "remote", "get-url", "crzy").CombinedOutput()
+	if strings.TrimSpace(string(output)) != "http://localhost:8080/"+filepath.Base(dir)+".git" {
+		t.Error("remote should point to the API, current:", string(output))
+	}
+}
@@ -0,0 +1,337 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsPath = "/metrics"
+
+var (
+	latencyBuckets  = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	pipelineBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600}
+)
+
+// counterVec is a counter with labels. The key of the values is the label
+// values joined with a null character.
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+// histogramVec is a histogram with labels.
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// metrics exports the pipeline, proxy and release metrics in the
+// Prometheus text format. Like the tracer and the notifiers, it can be nil.
+type metrics struct {
+	sync.Mutex
+	triggers      *counterVec
+	steps         *histogramVec
+	deploys       *counterVec
+	pushToLive    *histogramVec
+	requests      *counterVec
+	latency       *histogramVec
+	releaseStarts *counterVec
+	restarts      *counterVec
+	started       map[string]time.Time
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		triggers: &counterVec{
+			name: "crzy_triggers_total", help: "Number of pushes that triggered a version.",
+			values: map[string]float64{},
+		},
+		steps: &histogramVec{
+			name: "crzy_step_duration_seconds", help: "Duration of the workflow steps.",
+			labels: []string{"workflow", "step", "status"}, buckets: pipelineBuckets,
+			values: map[string]*histogram{},
+		},
+		deploys: &counterVec{
+			name: "crzy_deploys_total", help: "Number of deploys by status.",
+			labels: []string{"status"}, values: map[string]float64{},
+		},
+		pushToLive: &histogramVec{
+			name: "crzy_push_to_live_seconds", help: "Time from the push to the version being live.",
+			buckets: pipelineBuckets, values: map[string]*histogram{},
+		},
+		requests: &counterVec{
+			name: "crzy_proxy_requests_total", help: "Number of proxied requests by version and status code.",
+			labels: []string{"version", "code"}, values: map[string]float64{},
+		},
+		latency: &histogramVec{
+			name: "crzy_proxy_request_duration_seconds", help: "Latency of the proxied requests by version.",
+			labels: []string{"version"}, buckets: latencyBuckets,
+			values: map[string]*histogram{},
+		},
+		releaseStarts: &counterVec{
+			name: "crzy_release_starts_total", help: "Number of release processes started by status.",
+			labels: []string{"status"}, values: map[string]float64{},
+		},
+		restarts: &counterVec{
+			name: "crzy_release_restarts_total", help: "Number of release processes replaced by a new one.",
+			values: map[string]float64{},
+		},
+		started: map[string]time.Time{},
+	}
+}
+
+func labelKey(values ...string) string {
+	return strings.Join(values, "\x00")
+}
+
+func (c *counterVec) inc(values ...string) {
+	c.values[labelKey(values...)]++
+}
+
+func (h *histogramVec) observe(value float64, values ...string) {
+	key := labelKey(values...)
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for k, bucket := range h.buckets {
+		if value <= bucket {
+			v.counts[k]++
+		}
+	}
+	v.sum += value
+	v.count++
+}
+
+// firstLabel returns the value of the first label of a key.
+func firstLabel(key string) string {
+	return strings.SplitN(key, "\x00", 2)[0]
+}
+
+// keep deletes the series whose first label is not the value.
+func (c *counterVec) keep(value string) {
+	for k := range c.values {
+		if firstLabel(k) != value {
+			delete(c.values, k)
+		}
+	}
+}
+
+// keep deletes the series whose first label is not the value.
+func (h *histogramVec) keep(value string) {
+	for k := range h.values {
+		if firstLabel(k) != value {
+			delete(h.values, k)
+		}
+	}
+}
+
+func (m *metrics) trigger() {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.triggers.inc()
+}
+
+func (m *metrics) step(workflow, name, status string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.steps.observe(duration.Seconds(), workflow, name, status)
+}
+
+func (m *metrics) deploy(status string) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.deploys.inc(status)
+}
+
+// switched records the time from the push of a version to its switch.
+func (m *metrics) switched(sincePush time.Duration) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.pushToLive.observe(sincePush.Seconds())
+}
+
+func (m *metrics) request(version string, code int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.requests.inc(version, strconv.Itoa(code))
+	m.latency.observe(duration.Seconds(), version)
+}
+
+// releaseStarted records the start of a release process and whether it
+// replaces a running process. Once a version is live, the other processes
+// are stopped and the proxy series of the other versions are dropped.
+func (m *metrics) releaseStarted(version, status string, replaced bool) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.releaseStarts.inc(status)
+	if status != runnerStatusDone {
+		return
+	}
+	m.started = map[string]time.Time{version: time.Now()}
+	m.requests.keep(version)
+	m.latency.keep(version)
+	if replaced {
+		m.restarts.inc()
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatLabels(names []string, key string, extra ...string) string {
+	pairs := []string{}
+	if len(names) > 0 {
+		for k, v := range strings.Split(key, "\x00") {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", names[k], v))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(values interface{}) []string {
+	keys := []string{}
+	switch v := values.(type) {
+	case map[string]float64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if len(c.labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.values[""]))
+		return
+	}
+	for _, k := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, k), formatFloat(c.values[k]))
+	}
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, k := range sortedKeys(h.values) {
+		v := h.values[k]
+		for i, bucket := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, k, "le", formatFloat(bucket)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, k, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, k), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, k), v.count)
+	}
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+	m.triggers.write(w)
+	m.steps.write(w)
+	m.deploys.write(w)
+	m.pushToLive.write(w)
+	m.requests.write(w)
+	m.latency.write(w)
+	m.releaseStarts.write(w)
+	m.restarts.write(w)
+	fmt.Fprintf(w, "# HELP crzy_release_uptime_seconds Time since the live release process started.\n")
+	fmt.Fprintf(w, "# TYPE crzy_release_uptime_seconds gauge\n")
+	versions := []string{}
+	for k := range m.started {
+		versions = append(versions, k)
+	}
+	sort.Strings(versions)
+	for _, v := range versions {
+		fmt.Fprintf(w, "crzy_release_uptime_seconds{version=%q} %s\n", v, formatFloat(time.Since(m.started[v]).Seconds()))
+	}
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+// statusRecorder keeps the status code written by a handler. It flushes
+// and hijacks the underlying writer so that server-sent events and
+// WebSocket upgrades go through the proxy.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack records the upgrade as the response is then written on the
+// connection by the reverse proxy.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	s.code = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
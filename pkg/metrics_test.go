@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+func Test_metrics_nil(t *testing.T) {
+	var m *metrics
+	m.trigger()
+	m.step("deploy", "build", runnerStatusDone, time.Second)
+	m.deploy(runnerStatusDone)
+	m.switched(time.Second)
+	m.request("abc", 200, time.Millisecond)
+	m.releaseStarted("abc", runnerStatusDone, true)
+}
+
+func Test_metrics_write(t *testing.T) {
+	m := newMetrics()
+	m.trigger()
+	m.trigger()
+	m.step("deploy", "build", runnerStatusDone, 3*time.Second)
+	m.deploy(runnerStatusFailed)
+	m.switched(45 * time.Second)
+	m.releaseStarted("abc", runnerStatusDone, false)
+	m.request("abc", 200, 20*time.Millisecond)
+	m.releaseStarted("def", runnerStatusDone, true)
+	m.request("def", 502, 20*time.Millisecond)
+	m.releaseStarted("ghi", runnerStatusFailed, false)
+	output := &bytes.Buffer{}
+	m.write(output)
+	for _, v := range []string{
+		"crzy_triggers_total 2\n",
+		`crzy_step_duration_seconds_bucket{workflow="deploy",step="build",status="success",le="2"} 0` + "\n",
+		`crzy_step_duration_seconds_bucket{workflow="deploy",step="build",status="success",le="5"} 1` + "\n",
+		`crzy_step_duration_seconds_bucket{workflow="deploy",step="build",status="success",le="+Inf"} 1` + "\n",
+		`crzy_step_duration_seconds_sum{workflow="deploy",step="build",status="success"} 3` + "\n",
+		`crzy_deploys_total{status="failure"} 1` + "\n",
+		`crzy_push_to_live_seconds_count 1` + "\n",
+		`crzy_proxy_requests_total{version="def",code="502"} 1` + "\n",
+		`crzy_proxy_request_duration_seconds_bucket{version="def",le="0.025"} 1` + "\n",
+		`crzy_release_starts_total{status="success"} 2` + "\n",
+		`crzy_release_starts_total{status="failure"} 1` + "\n",
+		"crzy_release_restarts_total 1\n",
+		"# TYPE crzy_release_uptime_seconds gauge\n",
+		`crzy_release_uptime_seconds{version="def"}`,
+	} {
+		if !strings.Contains(output.String(), v) {
+			t.Errorf("output should contain %q, current:\n%s", v, output.String())
+		}
+	}
+	if strings.Contains(output.String(), `crzy_release_uptime_seconds{version="abc"}`) {
+		t.Error("the replaced version should not be up")
+	}
+	if strings.Contains(output.String(), `version="abc",code="200"`) || strings.Contains(output.String(), `crzy_proxy_request_duration_seconds_count{version="abc"}`) {
+		t.Error("the proxy series of the replaced version should be dropped")
+	}
+}
+
+func Test_metrics_ServeHTTP(t *testing.T) {
+	m := newMetrics()
+	m.trigger()
+	g := &gitServer{metrics: m}
+	server := httptest.NewServer(g.captureAndTrigger(http.NotFoundHandler()))
+	defer server.Close()
+	response, err := server.Client().Get(server.URL + metricsPath)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain") {
+		t.Error("should be text/plain, current:", response.Header.Get("Content-Type"))
+	}
+	b, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(b), "crzy_triggers_total 1\n") {
+		t.Error("should return the metrics, current:", string(b))
+	}
+}
+
+func Test_newReverseProxy_and_record(t *testing.T) {
+	r := &defaultContainer{
+		log:    &log.MockLogger{},
+		config: &config{},
+	}
+	server := httptest.NewServer(r.newReverseProxy(&mockUpstream{}))
+	defer server.Close()
+	response, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	response.Body.Close()
+	output := &bytes.Buffer{}
+	r.getMetrics().write(output)
+	if !strings.Contains(output.String(), `crzy_proxy_requests_total{version="",code="404"} 1`) {
+		t.Error("should record the request, current:", output.String())
+	}
+}
@@ -1,24 +1,421 @@
 package pkg
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
 
+	"github.com/go-logr/logr"
 	"github.com/slack-go/slack"
 )
 
+const (
+	notificationTailLines      = 20
+	slackTextSize              = 3000
+	notificationQueueSize      = 100
+	notificationAttempts       = 3
+	notificationHistory        = 50
+	defaultNotificationBackoff = time.Second
+
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+	deliveryDropped   = "dropped"
+
+	eventPushReceived    = "push_received"
+	eventStepFailed      = "step_failed"
+	eventDeploySucceeded = "deploy_succeeded"
+	eventReleaseSwitched = "release_switched"
+	eventReleaseFailed   = "release_failed"
+	eventRollback        = "rollback"
+)
+
 type notifierStruct struct {
-	Slack slackStruct
+	Slack      slackStruct
+	Email      emailStruct     `yaml:"email"`
+	Teams      []chatStruct    `yaml:"teams"`
+	Discord    []chatStruct    `yaml:"discord"`
+	Mattermost []chatStruct    `yaml:"mattermost"`
+	Webhooks   []webhookStruct `yaml:"webhooks"`
+	Forge      forgeStruct     `yaml:"forge"`
+}
+
+// notification is an event of the pipeline sent to the notifiers.
+type notification struct {
+	Event   string    `json:"event"`
+	Version string    `json:"version,omitempty"`
+	Branch  string    `json:"branch,omitempty"`
+	SHA     string    `json:"sha,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Step    string    `json:"step,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Output  string    `json:"output,omitempty"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// newNotification creates the notification of an event from the variables
+// of the version.
+func newNotification(event string, envs envVars) notification {
+	return notification{
+		Event:   event,
+		Version: envs.get("version"),
+		Branch:  envs.get("branch"),
+		SHA:     envs.get("commit_sha"),
+		Author:  envs.get("commit_author"),
+		Subject: envs.get("commit_subject"),
+	}
+}
+
+// tail returns the last lines of an output.
+func tail(output string, lines int) string {
+	results := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(results) > lines {
+		results = results[len(results)-lines:]
+	}
+	return strings.Join(results, "\n")
+}
+
+// truncate returns the end of an output that holds in size bytes, starting
+// on a complete character.
+func truncate(output string, size int) string {
+	if len(output) <= size {
+		return output
+	}
+	start := len(output) - size
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return output[start:]
+}
+
+// text returns the default message of the notification.
+func (n notification) text() string {
+	switch n.Event {
+	case eventPushReceived:
+		return fmt.Sprintf("version %s pushed on %s by %s", n.Version, n.Branch, n.Author)
+	case eventStepFailed:
+		return fmt.Sprintf("version %s has failed on step %s, error: %s", n.Version, n.Step, n.Error)
+	case eventDeploySucceeded:
+		return fmt.Sprintf("version %s has been deployed", n.Version)
+	case eventReleaseSwitched:
+		return fmt.Sprintf("version %s is live", n.Version)
+	case eventReleaseFailed:
+		return fmt.Sprintf("version %s has failed to start, error: %s", n.Version, n.Error)
+	case eventRollback:
+		return fmt.Sprintf("version %s is live again", n.Version)
+	}
+	return fmt.Sprintf("version %s: %s", n.Version, n.Event)
+}
+
+var (
+	errInvalidTemplate = errors.New("invalidtemplate")
+	errQueueFull       = errors.New("queuefull")
+	errChannelNotFound = errors.New("channelnotfound")
+
+	// notificationFuncs are the functions available in the templates of the
+	// notifications.
+	notificationFuncs = template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			output, err := json.Marshal(v)
+			return string(output), err
+		},
+		"short": func(v string) string {
+			if len(v) > 7 {
+				return v[:7]
+			}
+			return v
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}
+)
+
+// newMessageTemplate parses the template of the notifications shared by the
+// backends, it returns nil without a template.
+func newMessageTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New("notification").Funcs(notificationFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidTemplate, err)
+	}
+	return t, nil
+}
+
+// render executes the template with the notification or returns its
+// message without a template.
+func render(t *template.Template, n notification) (string, error) {
+	if t == nil {
+		return n.Message, nil
+	}
+	output := &strings.Builder{}
+	if err := t.Execute(output, n); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// notifier is a backend that sends the notifications, e.g. Slack or a
+// webhook. It ignores the events it does not accept.
+type notifier interface {
+	name() string
+	accept(event string) bool
+	notify(n notification) error
+}
+
+// retrier is implemented by the notifiers that configure how many times a
+// notification is sent before it fails.
+type retrier interface {
+	attempts() int
+}
+
+// delivery is the result of sending a notification to a backend.
+type delivery struct {
+	Event    string    `json:"event"`
+	Version  string    `json:"version,omitempty"`
+	Backend  string    `json:"backend,omitempty"`
+	Status   string    `json:"status"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type dataDeliveries struct {
+	Notifications []delivery `json:"notifications"`
+}
+
+// notifiers sends the notifications to every backend. Each backend has its
+// own queue, sent in the background with retries, so that a slow or failing
+// backend does not stall the workflows nor delay the other backends.
+type notifiers struct {
+	sync.Mutex
+	log        logr.Logger
+	secrets    *secrets
+	backends   []notifier
+	queues     []chan notification
+	backoff    time.Duration
+	deliveries []delivery
+}
+
+// newBackends creates the notifiers other than Slack from the
+// configuration. They do not connect to their server so that the
+// configuration can be validated when it is loaded.
+func newBackends(conf notifierStruct, dashboard string, s *secrets) ([]notifier, error) {
+	backends := []notifier{}
+	forge, err := newForgeNotifier(conf.Forge, dashboard, s)
+	if err != nil {
+		return nil, err
+	}
+	if forge != nil {
+		backends = append(backends, forge)
+	}
+	email, err := newEmailNotifier(conf.Email, s)
+	if err != nil {
+		return nil, err
+	}
+	if email != nil {
+		backends = append(backends, email)
+	}
+	chats := map[string][]chatStruct{teamsChat: conf.Teams, discordChat: conf.Discord, mattermostChat: conf.Mattermost}
+	for _, kind := range []string{teamsChat, discordChat, mattermostChat} {
+		for _, v := range chats[kind] {
+			c, err := newChatNotifier(kind, v, s)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, c)
+		}
+	}
+	for _, v := range conf.Webhooks {
+		w, err := newWebhookNotifier(v, s)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, w)
+	}
+	return backends, nil
+}
+
+// newNotifiers creates Slack and the other backends from the configuration.
+func newNotifiers(log logr.Logger, conf *config, s *secrets) (*notifiers, error) {
+	dashboard := dashboardURL(conf.Main.API)
+	backends, err := newBackends(conf.Notifier, dashboard, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range backends {
+		if f, ok := v.(*forgeNotifier); ok {
+			f.deployOnly = conf.Release.Run.Command == ""
+		}
+	}
+	n := &notifiers{
+		log:      log,
+		secrets:  s,
+		backends: append([]notifier{newSlackNotifier(conf.Notifier.Slack, dashboard, s)}, backends...),
+		backoff:  defaultNotificationBackoff,
+	}
+	return n.withQueues(), nil
 }
 
+// withQueues creates the queue of every backend. The notifications are
+// queued until start runs.
+func (n *notifiers) withQueues() *notifiers {
+	n.queues = make([]chan notification, len(n.backends))
+	for k := range n.queues {
+		n.queues[k] = make(chan notification, notificationQueueSize)
+	}
+	return n
+}
+
+// start sends the queued notifications of every backend until the context
+// is done.
+func (n *notifiers) start(ctx context.Context) error {
+	if n == nil {
+		<-ctx.Done()
+		return nil
+	}
+	wg := sync.WaitGroup{}
+	for k, queue := range n.queues {
+		wg.Add(1)
+		go func(backend notifier, queue chan notification) {
+			defer wg.Done()
+			for {
+				select {
+				case msg := <-queue:
+					if ctx.Err() != nil {
+						return
+					}
+					n.deliver(ctx, backend, msg)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(n.backends[k], queue)
+	}
+	wg.Wait()
+	return nil
+}
+
+// record keeps the last deliveries and logs the failures.
+func (n *notifiers) record(d delivery, err error) {
+	d.Time = time.Now()
+	if err != nil {
+		d.Error = n.secrets.mask(err.Error())
+		n.log.Error(err, "could not send notification", "event", d.Event, "backend", d.Backend)
+	}
+	n.Lock()
+	defer n.Unlock()
+	n.deliveries = append(n.deliveries, d)
+	if len(n.deliveries) > notificationHistory {
+		n.deliveries = n.deliveries[len(n.deliveries)-notificationHistory:]
+	}
+}
+
+// deliver sends the notification to a backend and retries with an
+// exponential backoff, notificationAttempts times unless the backend sets
+// its own number of attempts. It gives up when the context is done.
+func (n *notifiers) deliver(ctx context.Context, backend notifier, msg notification) {
+	limit := notificationAttempts
+	if r, ok := backend.(retrier); ok {
+		limit = r.attempts()
+	}
+	var err error
+	attempts := 0
+retry:
+	for attempts < limit {
+		if attempts > 0 {
+			select {
+			case <-time.After(n.backoff << (attempts - 1)):
+			case <-ctx.Done():
+				break retry
+			}
+		}
+		attempts++
+		if err = backend.notify(msg); err == nil {
+			break
+		}
+	}
+	status := deliveryDelivered
+	if err != nil {
+		status = deliveryFailed
+	}
+	n.record(delivery{Event: msg.Event, Version: msg.Version, Backend: backend.name(), Status: status, Attempts: attempts}, err)
+}
+
+// list returns the last deliveries, the most recent first.
+func (n *notifiers) list() []byte {
+	output := dataDeliveries{Notifications: []delivery{}}
+	if n != nil {
+		n.Lock()
+		for i := len(n.deliveries) - 1; i >= 0; i-- {
+			output.Notifications = append(output.Notifications, n.deliveries[i])
+		}
+		n.Unlock()
+	}
+	b, _ := json.Marshal(output)
+	return b
+}
+
+func (n *notifiers) notify(msg notification) {
+	if n == nil {
+		return
+	}
+	msg.Error = n.secrets.mask(msg.Error)
+	msg.Output = n.secrets.mask(msg.Output)
+	if msg.Message == "" {
+		msg.Message = msg.text()
+	}
+	msg.Message = n.secrets.mask(msg.Message)
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	for k, v := range n.backends {
+		if !v.accept(msg.Event) {
+			continue
+		}
+		// notifiers without queues, i.e. not created by newNotifiers,
+		// deliver right away
+		if k >= len(n.queues) {
+			n.deliver(context.Background(), v, msg)
+			continue
+		}
+		select {
+		case n.queues[k] <- msg:
+		default:
+			n.record(delivery{Event: msg.Event, Version: msg.Version, Backend: v.name(), Status: deliveryDropped}, errQueueFull)
+		}
+	}
+}
+
+// slackStruct configures Slack. Events maps the events to the channels
+// they are sent to, by default the release events are sent to Channel.
 type slackStruct struct {
 	Token   string
 	Channel string
+	Events  map[string]string `yaml:"events"`
 }
 
+var defaultSlackEvents = []string{eventStepFailed, eventReleaseSwitched, eventReleaseFailed, eventRollback}
+
+// slackNotifier sends the notifications as Block Kit messages. The messages
+// of a version are threaded in each channel. The channels are looked up
+// when they are first used.
 type slackNotifier struct {
+	sync.Mutex
 	messenger messenger
-	channelID string
+	events    map[string]string
+	channels  map[string]string
+	dashboard string
+	threads   map[string]string
 }
 
 type messenger interface {
@@ -41,52 +438,148 @@ func (m *mockMessenger) PostMessage(channelID string, options ...slack.MsgOption
 	return "", "", nil
 }
 
-func newSlackNotifier(s slackStruct) *slackNotifier {
+// newSlackNotifier creates the Slack client without calling the API. The
+// dashboard is the URL of the dashboard used in the links of the messages
+// and the token can reference the secrets with ${}.
+func newSlackNotifier(conf slackStruct, dashboard string, s *secrets) *slackNotifier {
 	output := &slackNotifier{
 		messenger: &mockMessenger{},
+		events:    map[string]string{},
+		channels:  map[string]string{},
+		threads:   map[string]string{},
 	}
-	evs := &envVars{}
-	token, err := evs.replace(s.Token)
+	envs := s.envs()
+	token, err := envs.replace(conf.Token)
 	if err != nil || token == "" {
 		return output
 	}
-	channel, err := evs.replace(s.Channel)
+	channel, err := envs.replace(conf.Channel)
 	if err != nil || channel == "" {
 		return output
 	}
-	api := slack.New(token)
-	notifier := &slackNotifier{
-		messenger: api,
+	output.messenger = slack.New(token)
+	output.dashboard = dashboard
+	output.events = conf.Events
+	if len(output.events) == 0 {
+		output.events = map[string]string{}
+		for _, v := range defaultSlackEvents {
+			output.events[v] = channel
+		}
 	}
-	channelID := getChannel(notifier, s.Channel)
-	if channelID == "" {
-		return output
+	return output
+}
+
+func (n *slackNotifier) name() string {
+	return "slack"
+}
+
+func (n *slackNotifier) accept(event string) bool {
+	_, ok := n.events[event]
+	return ok
+}
+
+// getChannel looks up the ID of a public channel, going through every page
+// of the conversations.
+func getChannel(n *slackNotifier, channel string) (string, error) {
+	params := &slack.GetConversationsParameters{
+		Types:           []string{"public_channel"},
+		ExcludeArchived: true,
+		Limit:           200,
+	}
+	for {
+		channels, cursor, err := n.messenger.GetConversations(params)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range channels {
+			if channel == c.Name {
+				return c.ID, nil
+			}
+		}
+		if cursor == "" {
+			return "", fmt.Errorf("%w: %s", errChannelNotFound, channel)
+		}
+		params.Cursor = cursor
 	}
-	notifier.channelID = channelID
-	return notifier
 }
 
-func getChannel(n *slackNotifier, channel string) string {
-	channels, _, err := n.messenger.GetConversations(
-		&slack.GetConversationsParameters{
-			Types: []string{"public_channel"},
-		})
+// channelID returns the ID of the channel and keeps it for the next
+// messages. It must be called with the lock.
+func (n *slackNotifier) channelID(channel string) (string, error) {
+	if id, ok := n.channels[channel]; ok {
+		return id, nil
+	}
+	id, err := getChannel(n, channel)
 	if err != nil {
-		fmt.Printf("%s\n", err)
-		return ""
+		return "", err
 	}
-	for _, c := range channels {
-		if channel == c.Name {
-			return c.ID
-		}
+	n.channels[channel] = id
+	return id, nil
+}
+
+// blocks formats the notification with Block Kit.
+func (n *slackNotifier) blocks(msg notification) []slack.Block {
+	fields := []*slack.TextBlockObject{
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Version*\n`%s`", msg.Version), false, false),
 	}
-	return ""
+	if msg.Branch != "" {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Branch*\n%s", msg.Branch), false, false))
+	}
+	if msg.Author != "" {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Author*\n%s", msg.Author), false, false))
+	}
+	if msg.SHA != "" {
+		commit := fmt.Sprintf("*Commit*\n`%.7s` %s", msg.SHA, msg.Subject)
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, strings.TrimSpace(commit), false, false))
+	}
+	if msg.Step != "" {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Step*\n%s", msg.Step), false, false))
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "*"+msg.Message+"*", false, false), fields, nil),
+	}
+	if msg.Output != "" {
+		// Slack rejects the sections with more than 3000 characters
+		output := truncate(msg.Output, slackTextSize-6)
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "```"+output+"```", false, false), nil, nil))
+	}
+	if n.dashboard != "" && msg.Version != "" {
+		button := slack.NewButtonBlockElement("dashboard", msg.Version,
+			slack.NewTextBlockObject(slack.PlainTextType, "Open the dashboard", false, false))
+		button.URL = n.dashboard + dashboardPath + "#" + url.PathEscape(msg.Version)
+		blocks = append(blocks, slack.NewActionBlock("", button))
+	}
+	return blocks
 }
 
-func (n *slackNotifier) sendMessage(msg string) error {
-	_, _, err := n.messenger.PostMessage(
-		n.channelID,
-		slack.MsgOptionText(msg, false),
-	)
-	return err
+// notify sends the notification to the channel of its event, in the thread
+// of the version.
+func (n *slackNotifier) notify(msg notification) error {
+	channel, ok := n.events[msg.Event]
+	if !ok {
+		return nil
+	}
+	n.Lock()
+	defer n.Unlock()
+	channelID, err := n.channelID(channel)
+	if err != nil {
+		return err
+	}
+	thread := channelID + "/" + msg.Version
+	options := []slack.MsgOption{
+		slack.MsgOptionText(msg.Message, false),
+		slack.MsgOptionBlocks(n.blocks(msg)...),
+	}
+	if ts, ok := n.threads[thread]; ok {
+		options = append(options, slack.MsgOptionTS(ts))
+	}
+	_, ts, err := n.messenger.PostMessage(channelID, options...)
+	if err != nil {
+		return err
+	}
+	if _, ok := n.threads[thread]; !ok && ts != "" && msg.Version != "" {
+		n.threads[thread] = ts
+	}
+	return nil
 }
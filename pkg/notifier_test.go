@@ -1,28 +1,77 @@
 package pkg
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	log "github.com/go-crzy/crzy/logr"
+	"github.com/slack-go/slack"
 	"gopkg.in/yaml.v3"
 )
 
 func Test_getChannel(t *testing.T) {
 	g := &slackNotifier{messenger: &mockMessenger{}}
-	channel := getChannel(g, "ops")
-	if channel != "123" {
-		t.Error("Should succeed", channel)
+	channel, err := getChannel(g, "ops")
+	if err != nil || channel != "123" {
+		t.Error("Should succeed", channel, err)
+	}
+	if _, err := getChannel(g, "demo"); !errors.Is(err, errChannelNotFound) {
+		t.Error("Should fail with errChannelNotFound, instead:", err)
+	}
+}
+
+type pagedMessenger struct {
+	mockMessenger
+	cursors []string
+}
+
+func (m *pagedMessenger) GetConversations(params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	m.cursors = append(m.cursors, params.Cursor)
+	if params.Cursor == "" {
+		return []slack.Channel{{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "1"}, Name: "general"}}}, "page2", nil
+	}
+	return []slack.Channel{{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "2"}, Name: "deploys"}}}, "", nil
+}
+
+func Test_getChannel_with_pages(t *testing.T) {
+	m := &pagedMessenger{}
+	s := &slackNotifier{
+		messenger: m,
+		events:    map[string]string{eventReleaseSwitched: "deploys"},
+		channels:  map[string]string{},
+		threads:   map[string]string{},
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.notify(notification{Event: eventReleaseSwitched, Version: "abc"}); err != nil {
+			t.Error("should succeed, error:", err)
+		}
+	}
+	if s.channels["deploys"] != "2" || len(m.cursors) != 2 || m.cursors[1] != "page2" {
+		t.Error("should look up the channel once through the pages, current:", s.channels, m.cursors)
 	}
 }
 
 func Test_sendMessage(t *testing.T) {
-	s := &slackNotifier{messenger: &mockMessenger{}}
-	err := s.sendMessage("titi")
+	s := &slackNotifier{
+		messenger: &mockMessenger{},
+		events:    map[string]string{eventRollback: "ops"},
+		channels:  map[string]string{},
+		threads:   map[string]string{},
+	}
+	err := s.notify(notification{Event: eventRollback, Message: "titi"})
 	if err != nil {
 		t.Error("Should succeed", err)
 	}
-	s.channelID = "wrong"
-	err = s.sendMessage("titi")
+	s.channels["ops"] = "wrong"
+	err = s.notify(notification{Event: eventRollback, Message: "titi"})
 	if err.Error() != "wrongChannel" {
 		t.Error("Should fail with wrongChannel, instead:", err)
 	}
@@ -50,6 +99,32 @@ notifier:
 	if c.Notifier.Slack.Token != "xoxb-xxxx" {
 		t.Error("error channel should be xoxb-xxxx")
 	}
+	fileContent = `
+notifier:
+  slack:
+    channel: demo
+    events:
+      step_failed: ops
+`
+	c = notifierTest{}
+	if err := yaml.Unmarshal([]byte(fileContent), &c); err != nil || c.Notifier.Slack.Events[eventStepFailed] != "ops" {
+		t.Error("should read the events, current:", c.Notifier.Slack.Events)
+	}
+	fileContent = `
+notifier:
+  webhooks:
+    - url: https://hooks.example.com/crzy
+      secret: ${HOOK_SECRET}
+      retries: 3
+      events: [step_failed, rollback]
+`
+	c = notifierTest{}
+	if err := yaml.Unmarshal([]byte(fileContent), &c); err != nil {
+		t.Error("error unmarshalling file")
+	}
+	if len(c.Notifier.Webhooks) != 1 || c.Notifier.Webhooks[0].Retries != 3 || len(c.Notifier.Webhooks[0].Events) != 2 {
+		t.Error("should read the webhooks, current:", c.Notifier.Webhooks)
+	}
 }
 
 func Test_newSlackNotifier(t *testing.T) {
@@ -63,7 +138,22 @@ channel: ""
 		t.Error("error unmarshalling file")
 	}
 	os.Setenv("SLACK_TOKEN", "xoxb-...")
-	newSlackNotifier(c)
+	newSlackNotifier(c, "http://localhost:8080", nil)
+}
+
+func Test_newSlackNotifier_with_secret(t *testing.T) {
+	c := slackStruct{Token: "${SLACK_SECRET}", Channel: "C0123"}
+	if _, ok := newSlackNotifier(c, "", nil).messenger.(*mockMessenger); !ok {
+		t.Error("should not connect without the secret")
+	}
+	s := &secrets{values: envVars{{Name: "SLACK_SECRET", Value: "xoxb-secret"}}}
+	n := newSlackNotifier(c, "http://localhost:8080", s)
+	if _, ok := n.messenger.(*mockMessenger); ok {
+		t.Error("should connect with the token from the secrets")
+	}
+	if n.events[eventReleaseSwitched] != "C0123" || n.events[eventStepFailed] != "C0123" {
+		t.Error("should notify the channel, current:", n.events)
+	}
 }
 
 func Test_notifier_config(t *testing.T) {
@@ -75,3 +165,208 @@ func Test_notifier_config(t *testing.T) {
 		t.Error("expecting ${SLACK_TOKEN}, got: ", c.Notifier.Slack.Channel)
 	}
 }
+
+type mockNotifier struct {
+	sync.Mutex
+	notifications []notification
+	failures      int
+	delay         time.Duration
+}
+
+func (m *mockNotifier) name() string {
+	return "mock"
+}
+
+func (m *mockNotifier) accept(event string) bool {
+	return event != eventPushReceived
+}
+
+func (m *mockNotifier) notify(n notification) error {
+	time.Sleep(m.delay)
+	m.Lock()
+	defer m.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("unavailable")
+	}
+	m.notifications = append(m.notifications, n)
+	return nil
+}
+
+func (m *mockNotifier) count() int {
+	m.Lock()
+	defer m.Unlock()
+	return len(m.notifications)
+}
+
+func Test_notifiers_notify(t *testing.T) {
+	var nilNotifiers *notifiers
+	nilNotifiers.notify(notification{Event: eventRollback})
+	m := &mockNotifier{}
+	n := &notifiers{
+		log:      &log.MockLogger{},
+		secrets:  &secrets{values: envVars{{Name: "TOKEN", Value: "s3cr3t"}}},
+		backends: []notifier{m, &slackNotifier{messenger: &mockMessenger{}}},
+	}
+	msg := newNotification(eventStepFailed, envVars{{Name: "version", Value: "abc"}, {Name: "branch", Value: "main"}})
+	msg.Step = "test"
+	msg.Error = "token s3cr3t is invalid"
+	n.notify(msg)
+	if len(m.notifications) != 1 {
+		t.Error("should notify the backends")
+		t.FailNow()
+	}
+	v := m.notifications[0]
+	if v.Branch != "main" || v.Error != "token *** is invalid" ||
+		v.Message != "version abc has failed on step test, error: token *** is invalid" || v.Time.IsZero() {
+		t.Error("should complete and mask the notification, current:", v)
+	}
+}
+
+type recordingMessenger struct {
+	mockMessenger
+	posts []url.Values
+}
+
+func (m *recordingMessenger) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		return "", "", err
+	}
+	m.posts = append(m.posts, values)
+	return channelID, fmt.Sprintf("1625000000.00%d", len(m.posts)), nil
+}
+
+func Test_slackNotifier_notify(t *testing.T) {
+	m := &recordingMessenger{}
+	s := &slackNotifier{
+		messenger: m,
+		events:    map[string]string{eventStepFailed: "deploys", eventReleaseSwitched: "deploys"},
+		channels:  map[string]string{"deploys": "C1"},
+		dashboard: "https://crzy.example.com",
+		threads:   map[string]string{},
+	}
+	if err := s.notify(notification{Event: eventPushReceived, Version: "abc"}); err != nil || len(m.posts) != 0 {
+		t.Error("should ignore the push, error:", err)
+	}
+	failed := notification{
+		Event: eventStepFailed, Version: "abc", SHA: "0123456789abcdef", Author: "Jane <jane@example.com>",
+		Subject: "fix the tests", Step: "test", Output: "--- FAIL: Test_main", Message: "version abc has failed",
+	}
+	if err := s.notify(failed); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if err := s.notify(notification{Event: eventReleaseSwitched, Version: "abc", Message: "version abc is live"}); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if len(m.posts) != 2 {
+		t.Error("should post 2 messages, current:", len(m.posts))
+		t.FailNow()
+	}
+	blocks := m.posts[0].Get("blocks")
+	for _, v := range []string{"`0123456`", "fix the tests", "Jane", "*Step*\\ntest", "--- FAIL: Test_main", "https://crzy.example.com/ui/#abc"} {
+		if !strings.Contains(blocks, v) {
+			t.Errorf("blocks should contain %q, current: %s", v, blocks)
+		}
+	}
+	failed.Output = strings.Repeat("x", 4000) + "end"
+	if err := s.notify(failed); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	text := strings.Repeat("x", slackTextSize-9) + "end"
+	if blocks := m.posts[2].Get("blocks"); strings.Contains(blocks, "x"+text) || !strings.Contains(blocks, text) {
+		t.Error("should truncate the output to the size of a section")
+	}
+	if m.posts[0].Get("thread_ts") != "" || m.posts[1].Get("thread_ts") != "1625000000.001" {
+		t.Error("should thread the messages of the version, current:", m.posts[1].Get("thread_ts"))
+	}
+}
+
+func Test_tail(t *testing.T) {
+	if v := tail("1\n2\n3\n4\n", 2); v != "3\n4" {
+		t.Error("should return the last lines, current:", v)
+	}
+	if v := tail("1", 2); v != "1" {
+		t.Error("should return the output, current:", v)
+	}
+	if v := truncate("été", 3); v != "té" {
+		t.Error("should return the last complete characters, current:", v)
+	}
+	if v := truncate("1", 3); v != "1" {
+		t.Error("should return the output, current:", v)
+	}
+}
+
+func Test_notifiers_queue(t *testing.T) {
+	m := &mockNotifier{failures: 1, delay: 50 * time.Millisecond}
+	n := (&notifiers{log: &log.MockLogger{}, backends: []notifier{m}, backoff: time.Millisecond}).withQueues()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.start(ctx)
+	start := time.Now()
+	n.notify(notification{Event: eventPushReceived, Version: "abc"})
+	n.notify(notification{Event: eventDeploySucceeded, Version: "abc"})
+	if time.Since(start) > 40*time.Millisecond {
+		t.Error("should not wait for the backend")
+	}
+	for i := 0; i < 100 && m.count() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	output := dataDeliveries{}
+	json.Unmarshal(n.list(), &output)
+	if m.count() != 1 || len(output.Notifications) != 1 {
+		t.Error("should deliver the accepted event, current:", output)
+		t.FailNow()
+	}
+	if v := output.Notifications[0]; v.Status != deliveryDelivered || v.Attempts != 2 || v.Backend != "mock" {
+		t.Error("should retry the delivery, current:", v)
+	}
+}
+
+func Test_notifiers_failure_and_full(t *testing.T) {
+	m := &mockNotifier{failures: notificationAttempts}
+	n := &notifiers{log: &log.MockLogger{}, backends: []notifier{m}, backoff: time.Millisecond}
+	n.notify(notification{Event: eventStepFailed, Version: "abc"})
+	n.queues = []chan notification{make(chan notification)}
+	n.notify(notification{Event: eventRollback, Version: "def"})
+	output := dataDeliveries{}
+	json.Unmarshal(n.list(), &output)
+	if len(output.Notifications) != 2 {
+		t.Error("should record the deliveries, current:", output)
+		t.FailNow()
+	}
+	if v := output.Notifications[1]; v.Status != deliveryFailed || v.Attempts != notificationAttempts || v.Error != "unavailable" {
+		t.Error("should record the failure, current:", v)
+	}
+	if v := output.Notifications[0]; v.Status != deliveryDropped || v.Version != "def" || v.Backend != "mock" {
+		t.Error("should drop the notification when the queue is full, current:", v)
+	}
+}
+
+func Test_notifiers_queue_per_backend(t *testing.T) {
+	failing := &mockNotifier{failures: notificationAttempts}
+	working := &mockNotifier{}
+	n := (&notifiers{log: &log.MockLogger{}, backends: []notifier{failing, working}, backoff: time.Second}).withQueues()
+	n.notify(notification{Event: eventStepFailed, Version: "abc"})
+	n.notify(notification{Event: eventRollback, Version: "abc"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- n.start(ctx) }()
+	for i := 0; i < 100 && working.count() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if working.count() != 2 {
+		t.Error("should not wait for the failing backend, current:", working.count())
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Error("should stop during the backoff")
+	}
+	output := dataDeliveries{}
+	json.Unmarshal(n.list(), &output)
+	if len(output.Notifications) != 3 || output.Notifications[0].Status != deliveryFailed || output.Notifications[0].Attempts != 1 {
+		t.Error("should record the interrupted delivery, current:", output)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -13,8 +14,29 @@ func (r *defaultContainer) newReverseProxy(u upstream) http.Handler {
 	transport := &http.Transport{
 		TLSHandshakeTimeout: 10 * time.Second,
 	}
-	return r.config.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		v, err := u.getDefault()
+	metrics := r.getMetrics()
+	tracer := r.getTracer()
+	return r.config.corsMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w := &statusRecorder{ResponseWriter: rw, code: http.StatusOK}
+		live, err := u.getDefault()
+		parent, _ := parseTraceparent(r.Header.Get(traceparentHeader))
+		s := tracer.start(parent, "proxy "+r.Method, spanKindServer)
+		defer func() {
+			metrics.request(live.Version, w.code, time.Since(start))
+			s.setAttribute("crzy.version", live.Version)
+			s.setAttribute("http.method", r.Method)
+			s.setAttribute("http.target", r.URL.RequestURI())
+			s.setAttribute("http.status_code", strconv.Itoa(w.code))
+			if w.code >= http.StatusInternalServerError {
+				s.finish(errors.New(http.StatusText(w.code)))
+				return
+			}
+			s.finish(nil)
+		}()
+		if s != nil {
+			r.Header.Set(traceparentHeader, s.spanContext().traceparent())
+		}
 		if err == errServiceNotFound {
 			http.Error(w, `{"message": "NotFound"}`, http.StatusNotFound)
 			return
@@ -22,7 +44,7 @@ func (r *defaultContainer) newReverseProxy(u upstream) http.Handler {
 		(&httputil.ReverseProxy{
 			Director: func(req *http.Request) {
 				req.URL.Scheme = "http"
-				req.URL.Host = v
+				req.URL.Host = live.Upstream
 			},
 			Transport: transport,
 		}).ServeHTTP(w, r)
@@ -31,8 +53,8 @@ func (r *defaultContainer) newReverseProxy(u upstream) http.Handler {
 
 type defaultUpstream struct {
 	sync.RWMutex
-	defaultUpstream *string
-	state           state
+	live  *liveVersion
+	state state
 }
 
 func newUpstream(state state) upstream {
@@ -48,28 +70,29 @@ var (
 
 // Upstreamer the backend registration interface
 type upstream interface {
-	setDefault(string)
-	getDefault() (string, error)
+	setDefault(version, upstream string)
+	getDefault() (liveVersion, error)
 	listVersions() []byte
 }
 
 // SetDefault an upstream server for a service version
-func (u *defaultUpstream) setDefault(name string) {
+func (u *defaultUpstream) setDefault(version, upstream string) {
 	u.Lock()
 	defer u.Unlock()
-	u.defaultUpstream = &name
+	u.live = &liveVersion{Version: version, Upstream: upstream}
 }
 
-// GetDefault an upstream server for a service version
-func (u *defaultUpstream) getDefault() (string, error) {
-	u.Lock()
-	defer u.Unlock()
-	if u.defaultUpstream == nil {
-		return "", errServiceNotFound
+// GetDefault returns the live version with its upstream server
+func (u *defaultUpstream) getDefault() (liveVersion, error) {
+	u.RLock()
+	defer u.RUnlock()
+	if u.live == nil {
+		return liveVersion{}, errServiceNotFound
 	}
-	return *u.defaultUpstream, nil
+	return *u.live, nil
 }
 
 func (u *defaultUpstream) listVersions() []byte {
-	return u.state.listVersions()
+	output, _ := u.state.listVersions(versionQuery{})
+	return output
 }
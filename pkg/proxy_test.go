@@ -1,11 +1,15 @@
 package pkg
 
 import (
+	"bufio"
+	"bytes"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	log "github.com/go-crzy/crzy/logr"
 )
@@ -13,16 +17,16 @@ import (
 type mockUpstream struct{}
 
 // setDefault an upstream server for a service version
-func (u *mockUpstream) setDefault(name string) {
+func (u *mockUpstream) setDefault(version, upstream string) {
 }
 
 // GetDefault an upstream server for a service version
-func (u *mockUpstream) getDefault() (string, error) {
-	return "", errServiceNotFound
+func (u *mockUpstream) getDefault() (liveVersion, error) {
+	return liveVersion{}, errServiceNotFound
 }
 
 func (u *mockUpstream) listVersions() []byte {
-	return []byte(`{"versions": ["123"]}`)
+	return []byte(`{"versions": [{"version":"123"}]}`)
 }
 
 func Test_newReverseProxy_with_404(t *testing.T) {
@@ -61,13 +65,13 @@ func Test_defaultUpstream(t *testing.T) {
 	if err != errServiceNotFound {
 		t.Errorf("should returm errServiceNotFound, returns %v", err)
 	}
-	u.setDefault("localhost:8090")
+	u.setDefault("123", "localhost:8090")
 	h, err := u.getDefault()
 	if err != nil {
 		t.Errorf("should succeed, returns %v", err)
 	}
-	if h != "localhost:8090" {
-		t.Errorf("should return localhost:8090, returns %v", h)
+	if h.Upstream != "localhost:8090" || h.Version != "123" {
+		t.Errorf("should return 123 on localhost:8090, returns %v", h)
 	}
 }
 
@@ -77,5 +81,62 @@ func Test_mockUpstream(t *testing.T) {
 	if err != errServiceNotFound {
 		t.Errorf("should returm errServiceNotFound, returns %v", err)
 	}
-	u.setDefault("localhost:8090")
+	u.setDefault("123", "localhost:8090")
+}
+
+func Test_newReverseProxy_with_upgrade(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+		line, _ := buf.ReadString('\n')
+		buf.WriteString(line)
+		buf.Flush()
+	}))
+	defer backend.Close()
+	r := &defaultContainer{
+		log:    &log.MockLogger{},
+		config: &config{},
+	}
+	u := newUpstream(&defaultState{})
+	u.setDefault("123", strings.TrimPrefix(backend.URL, "http://"))
+	server := httptest.NewServer(r.newReverseProxy(u))
+	defer server.Close()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Error("should connect, error:", err)
+		t.FailNow()
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil || response.StatusCode != http.StatusSwitchingProtocols {
+		t.Error("should switch protocols, error:", err)
+		t.FailNow()
+	}
+	conn.Write([]byte("ping\n"))
+	if line, _ := reader.ReadString('\n'); line != "ping\n" {
+		t.Errorf("should echo through the proxy, current: %q", line)
+	}
+	conn.Close()
+	output := &bytes.Buffer{}
+	for i := 0; i < 20; i++ {
+		output.Reset()
+		r.getMetrics().write(output)
+		if strings.Contains(output.String(), `crzy_proxy_requests_total{version="123",code="101"} 1`) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("should record the upgrade once closed, current:", output.String())
 }
@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	webhooksPath   = "/v0/webhooks/"
+	maxPushPayload = 5 << 20
+	zeroSHA        = "0000000000000000000000000000000000000000"
+)
+
+var (
+	errInvalidRemote    = errors.New("invalidremote")
+	errInvalidSignature = errors.New("invalidsignature")
+	errPushOutdated     = errors.New("pushoutdated")
+)
+
+// remoteStruct configures the repository fetched when the forge sends a push
+// webhook to /v0/webhooks/github, /v0/webhooks/gitlab or /v0/webhooks/gitea.
+// The url and the secret can reference the secrets with ${}.
+type remoteStruct struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+}
+
+// remote is the repository of the forge and the secret of its webhooks.
+type remote struct {
+	url    string
+	secret string
+}
+
+// newRemote returns nil when no url is configured. The secret is required
+// so that anyone cannot trigger a version.
+func newRemote(conf remoteStruct, s *secrets) (*remote, error) {
+	if conf.URL == "" {
+		return nil, nil
+	}
+	envs := s.envs()
+	address, err := envs.replace(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := envs.replace(conf.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, errInvalidRemote
+	}
+	return &remote{url: address, secret: secret}, nil
+}
+
+// verify checks the signature of the webhook: GitHub and Gitea sign the
+// payload with HMAC-SHA256, GitLab sends the secret as a token.
+func (r *remote) verify(provider string, header http.Header, body []byte) error {
+	expected := ""
+	signature := ""
+	switch provider {
+	case githubForge:
+		expected = sign(r.secret, body)
+		signature = header.Get("X-Hub-Signature-256")
+	case giteaForge:
+		expected = strings.TrimPrefix(sign(r.secret, body), "sha256=")
+		signature = header.Get("X-Gitea-Signature")
+	case gitlabForge:
+		expected = r.secret
+		signature = header.Get("X-Gitlab-Token")
+	}
+	if signature == "" || !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// pushEvents are the header and the value of the push events of the forges.
+var pushEvents = map[string][2]string{
+	githubForge: {"X-GitHub-Event", "push"},
+	gitlabForge: {"X-Gitlab-Event", "Push Hook"},
+	giteaForge:  {"X-Gitea-Event", "push"},
+}
+
+// pushPayload is the part of the push webhooks shared by the forges.
+type pushPayload struct {
+	Ref   string `json:"ref"`
+	After string `json:"after"`
+}
+
+// pushHandler fetches the branch from the remote when the forge sends a push
+// webhook and triggers a version like a push to the git server.
+type pushHandler struct {
+	log    logr.Logger
+	remote *remote
+	head   string
+	git    gitCommand
+	action chan<- event
+	tracer *tracer
+}
+
+func (p *pushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	provider := strings.TrimPrefix(r.URL.Path, webhooksPath)
+	header, ok := pushEvents[provider]
+	if p.remote == nil || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{"message":"method not allowed"}`))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushPayload))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"bad request"}`))
+		return
+	}
+	if err := p.remote.verify(provider, r.Header, body); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"invalid signature"}`))
+		return
+	}
+	payload := pushPayload{}
+	if r.Header.Get(header[0]) != header[1] || json.Unmarshal(body, &payload) != nil ||
+		payload.Ref != "refs/heads/"+p.head || payload.After == "" || payload.After == zeroSHA {
+		w.Write([]byte(`{"message":"ignored"}`))
+		return
+	}
+	parent, _ := parseTraceparent(r.Header.Get(traceparentHeader))
+	s := p.tracer.start(parent, "git.fetch", spanKindServer)
+	s.setAttribute("crzy.provider", provider)
+	s.setAttribute("crzy.commit_sha", payload.After)
+	sha, err := p.git.fetchRemote(p.remote.url, p.head)
+	if err == nil && sha != payload.After {
+		err = fmt.Errorf("%w: %s", errPushOutdated, sha)
+	}
+	s.finish(err)
+	if errors.Is(err, errPushOutdated) {
+		p.log.Info("branch moved since the push, ignoring...", "data", payload.After)
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"conflict"}`))
+		return
+	}
+	if err != nil {
+		p.log.Error(err, "could not fetch the remote", "data", payload.After)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"error"}`))
+		return
+	}
+	p.action <- event{id: triggeredMessage, trace: s.spanContext()}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"message":"triggered"}`))
+}
@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+func Test_newRemote(t *testing.T) {
+	r, err := newRemote(remoteStruct{}, nil)
+	if err != nil || r != nil {
+		t.Error("should be disabled without url", err)
+	}
+	if _, err := newRemote(remoteStruct{URL: "https://github.com/go-crzy/crzy.git"}, nil); err != errInvalidRemote {
+		t.Error("should fail with errInvalidRemote, error:", err)
+	}
+	s := &secrets{values: envVars{{Name: "HOOK_SECRET", Value: "s3cr3t"}}}
+	r, err = newRemote(remoteStruct{URL: "https://github.com/go-crzy/crzy.git", Secret: "${HOOK_SECRET}"}, s)
+	if err != nil || r.secret != "s3cr3t" {
+		t.Error("should replace the secret", err)
+	}
+}
+
+func Test_remote_verify(t *testing.T) {
+	r := &remote{secret: "s3cr3t"}
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	data := []struct {
+		provider string
+		header   string
+		value    string
+		err      error
+	}{
+		{provider: githubForge, header: "X-Hub-Signature-256", value: sign("s3cr3t", body), err: nil},
+		{provider: githubForge, header: "X-Hub-Signature-256", value: sign("wrong", body), err: errInvalidSignature},
+		{provider: giteaForge, header: "X-Gitea-Signature", value: strings.TrimPrefix(sign("s3cr3t", body), "sha256="), err: nil},
+		{provider: giteaForge, header: "X-Gitea-Signature", value: "", err: errInvalidSignature},
+		{provider: gitlabForge, header: "X-Gitlab-Token", value: "s3cr3t", err: nil},
+		{provider: gitlabForge, header: "X-Gitlab-Token", value: "wrong", err: errInvalidSignature},
+	}
+	for _, v := range data {
+		header := http.Header{}
+		header.Set(v.header, v.value)
+		if err := r.verify(v.provider, header, body); err != v.err {
+			t.Errorf("%s with %q: expect %v, get: %v", v.provider, v.value, v.err, err)
+		}
+	}
+}
+
+func Test_pushHandler(t *testing.T) {
+	action := make(chan event, 1)
+	handler := &pushHandler{
+		log:    &log.MockLogger{},
+		remote: &remote{url: "https://github.com/go-crzy/crzy.git", secret: "s3cr3t"},
+		head:   "main",
+		git:    &mockGitSuccessCommand{},
+		action: action,
+	}
+	push := `{"ref":"refs/heads/main","after":"0123456789abcdef"}`
+	data := []struct {
+		name     string
+		method   string
+		route    string
+		input    string
+		event    string
+		secret   string
+		git      gitCommand
+		status   int
+		output   string
+		expected bool
+	}{
+		{name: "push_and_succeed", method: http.MethodPost, route: "/v0/webhooks/github", input: push, event: "push", secret: "s3cr3t", status: http.StatusAccepted, output: `{"message":"triggered"}`, expected: true},
+		{name: "push_with_wrong_signature", method: http.MethodPost, route: "/v0/webhooks/github", input: push, event: "push", secret: "wrong", status: http.StatusUnauthorized, output: `{"message":"invalid signature"}`},
+		{name: "push_on_another_branch", method: http.MethodPost, route: "/v0/webhooks/github", input: `{"ref":"refs/heads/dev","after":"0123456789abcdef"}`, event: "push", secret: "s3cr3t", status: http.StatusOK, output: `{"message":"ignored"}`},
+		{name: "delete_branch", method: http.MethodPost, route: "/v0/webhooks/github", input: `{"ref":"refs/heads/main","after":"` + zeroSHA + `"}`, event: "push", secret: "s3cr3t", status: http.StatusOK, output: `{"message":"ignored"}`},
+		{name: "ping", method: http.MethodPost, route: "/v0/webhooks/github", input: `{"zen":"keep it simple"}`, event: "ping", secret: "s3cr3t", status: http.StatusOK, output: `{"message":"ignored"}`},
+		{name: "push_outdated", method: http.MethodPost, route: "/v0/webhooks/github", input: `{"ref":"refs/heads/main","after":"fedcba9876543210"}`, event: "push", secret: "s3cr3t", status: http.StatusConflict, output: `{"message":"conflict"}`},
+		{name: "push_and_fail_to_fetch", method: http.MethodPost, route: "/v0/webhooks/github", input: push, event: "push", secret: "s3cr3t", git: &mockGitFailCommand{}, status: http.StatusInternalServerError, output: `{"message":"error"}`},
+		{name: "get_and_fail", method: http.MethodGet, route: "/v0/webhooks/github", status: http.StatusMethodNotAllowed, output: `{"message":"method not allowed"}`},
+		{name: "unknown_provider", method: http.MethodPost, route: "/v0/webhooks/bitbucket", status: http.StatusNotFound, output: `{"message":"not found"}`},
+	}
+	for _, v := range data {
+		handler.git = v.git
+		if v.git == nil {
+			handler.git = &mockGitSuccessCommand{}
+		}
+		request := httptest.NewRequest(v.method, v.route, bytes.NewBufferString(v.input))
+		request.Header.Set("X-GitHub-Event", v.event)
+		request.Header.Set("X-Hub-Signature-256", sign(v.secret, []byte(v.input)))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != v.status || recorder.Body.String() != v.output {
+			t.Errorf("%s: expect %d %s, get: %d %s", v.name, v.status, v.output, recorder.Code, recorder.Body.String())
+		}
+		if (len(action) == 1) != v.expected {
+			t.Errorf("%s: should trigger %t", v.name, v.expected)
+		}
+		if v.expected && (<-action).id != triggeredMessage {
+			t.Errorf("%s: should send a triggered message", v.name)
+		}
+	}
+}
+
+func Test_pushHandler_gitlab(t *testing.T) {
+	action := make(chan event, 1)
+	handler := &pushHandler{
+		log:    &log.MockLogger{},
+		remote: &remote{url: "https://gitlab.com/go-crzy/crzy.git", secret: "s3cr3t"},
+		head:   "main",
+		git:    &mockGitSuccessCommand{},
+		action: action,
+	}
+	request := httptest.NewRequest(http.MethodPost, "/v0/webhooks/gitlab", bytes.NewBufferString(`{"ref":"refs/heads/main","after":"0123456789abcdef"}`))
+	request.Header.Set("X-Gitlab-Event", "Push Hook")
+	request.Header.Set("X-Gitlab-Token", "s3cr3t")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusAccepted || len(action) != 1 {
+		t.Error("should trigger the version, current:", recorder.Code, recorder.Body.String())
+	}
+	handler.remote = nil
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v0/webhooks/gitlab", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Error("should fail without remote, current:", recorder.Code)
+	}
+}
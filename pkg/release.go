@@ -19,21 +19,32 @@ type releaseWorkflow struct {
 	files          map[string][]*file
 	flow           string
 	processes      map[string]*os.Process
-	switchUpstream func(string)
+	containers     map[string][]string
+	switchUpstream func(version, upstream string)
+	checks         []check
+	verifyBackoff  time.Duration
 	state          stateClient
-	slack          *slackNotifier
+	notifiers      *notifiers
+	artifacts      *artifacts
+	metrics        *metrics
+	tracer         *tracer
 }
 
 func deepCopy(e execStruct) execStruct {
 	output := execStruct{
-		log:     e.log,
-		name:    e.name,
-		Command: e.Command,
-		Args:    []string{},
-		WorkDir: e.WorkDir,
-		Envs:    envVars{},
-		Output:  e.Output,
-		files:   e.files,
+		log:      e.log,
+		name:     e.name,
+		runtime:  e.runtime,
+		secrets:  e.secrets,
+		Command:  e.Command,
+		Args:     []string{},
+		WorkDir:  e.WorkDir,
+		Envs:     envVars{},
+		Output:   e.Output,
+		Image:    e.Image,
+		EnvFile:  e.EnvFile,
+		Branches: e.Branches,
+		files:    e.files,
 	}
 	output.Args = append(output.Args, e.Args...)
 	for _, v := range e.Envs {
@@ -58,7 +69,7 @@ func (w *releaseWorkflow) start(ctx context.Context, action <-chan event) error
 		case action := <-action:
 			log.Info("release started...")
 			switch action.id {
-			case deployedMessage:
+			case deployedMessage, rollbackMessage:
 				vars := newEnvVars(action.envs...)
 				p, err := port.getPort()
 				if err != nil {
@@ -70,13 +81,19 @@ func (w *releaseWorkflow) start(ctx context.Context, action <-chan event) error
 				if cmd.Command == "" {
 					continue
 				}
-				err = w.switchProcesses(p, cmd, vars)
+				err = w.switchProcesses(p, cmd, vars, action.trace)
 				if err != nil {
 					log.Error(err, "execution error")
-					w.slack.sendMessage(cmd.Command + " has failed to start, error: " + err.Error())
+					n := newNotification(eventReleaseFailed, vars)
+					n.Error = err.Error()
+					w.notifiers.notify(n)
 					continue
 				}
-				w.slack.sendMessage(cmd.Command + " has started on " + p)
+				event := eventReleaseSwitched
+				if action.id == rollbackMessage {
+					event = eventRollback
+				}
+				w.notifiers.notify(newNotification(event, vars))
 				log.Info("release execution succeeded...")
 			}
 		case <-ctx.Done():
@@ -87,17 +104,33 @@ func (w *releaseWorkflow) start(ctx context.Context, action <-chan event) error
 }
 
 func (r *releaseWorkflow) killAll() error {
-	for k, v := range r.processes {
-		err := v.Kill()
-		if err != nil {
+	for k := range r.processes {
+		if err := r.kill(k); err != nil {
 			return err
 		}
-		delete(r.processes, k)
-		delete(r.files, k)
 	}
 	return nil
 }
 
+// kill stops the process running on the port and removes its container
+// when it runs in one: killing the runtime client does not stop it.
+func (r *releaseWorkflow) kill(port string) error {
+	if process, ok := r.processes[port]; ok {
+		if err := process.Kill(); err != nil {
+			return err
+		}
+	}
+	if c, ok := r.containers[port]; ok {
+		if output, err := getCmd(r.execdir, envVars{}, c[0], c[1:]...).CombinedOutput(); err != nil {
+			r.log.Error(err, "could not remove container", "data", string(output))
+		}
+		delete(r.containers, port)
+	}
+	delete(r.processes, port)
+	delete(r.files, port)
+	return nil
+}
+
 var errConnectionFailed = errors.New("connectionfailed")
 
 func (r *releaseWorkflow) checkConnect(host string, port string, timeout time.Duration) error {
@@ -121,10 +154,16 @@ func (r *releaseWorkflow) checkConnect(host string, port string, timeout time.Du
 	}
 }
 
-func (r *releaseWorkflow) switchProcesses(port string, command execStruct, envs envVars) error {
+// switchProcesses starts the version on the port and switches the proxy to
+// it once it listens. The trace is the one of the push.
+func (r *releaseWorkflow) switchProcesses(port string, command execStruct, envs envVars, trace spanContext) (err error) {
 	envs.addOne("port", port)
+	s := r.tracer.start(trace, "release.switch", spanKindInternal)
+	s.setAttribute("crzy.version", envs.get("version"))
+	s.setAttribute("crzy.port", port)
+	defer func() { s.finish(err) }()
 	workflow := &workflow{
-		log:     r.log,
+		log:     r.log.WithName("release"),
 		version: envs.get("version"),
 		name:    "release",
 		basedir: r.execdir,
@@ -133,24 +172,59 @@ func (r *releaseWorkflow) switchProcesses(port string, command execStruct, envs
 	}
 	process, err := workflow.start(&command)
 	if err != nil {
+		r.metrics.releaseStarted(envs.get("version"), runnerStatusFailed, false)
 		return err
 	}
 	r.processes[port] = process
 	r.files[port] = command.files
+	if command.Image != "" {
+		if r.containers == nil {
+			r.containers = map[string][]string{}
+		}
+		r.containers[port] = []string{command.getRuntime(), "rm", "-f", command.containerName(envs)}
+	}
+	for _, f := range command.files {
+		r.artifacts.add(envs.get("version"), f.filename)
+	}
 	err = r.checkConnect("localhost", port, 30*time.Second)
 	if err != nil {
+		r.metrics.releaseStarted(envs.get("version"), runnerStatusFailed, false)
 		r.log.Error(err, "cannot find port before switching")
+		if err := r.kill(port); err != nil {
+			r.log.Error(err, "could not stop the version", "data", port)
+		}
 		return err
 	}
-	r.switchUpstream("localhost:" + port)
-	for k, v := range r.processes {
+	err = r.verify(port, envs, trace)
+	if err != nil {
+		r.metrics.releaseStarted(envs.get("version"), runnerStatusFailed, false)
+		r.log.Error(err, "verification failed, keeping the live version")
+		if err := r.kill(port); err != nil {
+			r.log.Error(err, "could not stop the version", "data", port)
+		}
+		return err
+	}
+	r.metrics.releaseStarted(envs.get("version"), runnerStatusDone, len(r.processes) > 1)
+	upstream := "localhost:" + port
+	r.switchUpstream(envs.get("version"), upstream)
+	start := time.Now()
+	r.state.notifyStep(
+		envs.get("version"),
+		"release",
+		runnerStatusDone,
+		step{
+			execStruct: execStruct{Command: "switch"},
+			Name:       "switch",
+			StartTime:  &start,
+			Variables:  envVars{{Name: "upstream", Value: upstream}},
+		})
+	r.artifacts.setLive(envs.get("version"))
+	r.artifacts.gc()
+	for k := range r.processes {
 		if k != port {
-			err := v.Kill()
-			if err != nil {
+			if err := r.kill(k); err != nil {
 				return err
 			}
-			delete(r.processes, k)
-			delete(r.files, k)
 		}
 	}
 	return nil
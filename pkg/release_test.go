@@ -28,10 +28,10 @@ func Test_releaseWorkflow(t *testing.T) {
 		},
 		state:          &stateMockClient{},
 		flow:           "run",
-		switchUpstream: func(string) {},
+		switchUpstream: func(string, string) {},
 		processes:      map[string]*os.Process{},
 		files:          make(map[string][]*file),
-		slack:          &slackNotifier{messenger: &mockMessenger{}},
+		notifiers:      &notifiers{log: &log.MockLogger{}, backends: []notifier{&slackNotifier{messenger: &mockMessenger{}}}},
 	}
 	if runtime.GOOS == "windows" {
 		release.Run.Command = "powershell"
@@ -70,3 +70,19 @@ func Test_deepCopy(t *testing.T) {
 		t.Error("should have one unsubtituted value")
 	}
 }
+
+func Test_kill_with_container(t *testing.T) {
+	release := &releaseWorkflow{
+		log:        &log.MockLogger{},
+		execdir:    ".",
+		processes:  map[string]*os.Process{},
+		containers: map[string][]string{"8090": {"git", "version"}},
+		files:      map[string][]*file{"8090": {}},
+	}
+	if err := release.kill("8090"); err != nil {
+		t.Error("kill should succeed", err)
+	}
+	if len(release.containers) != 0 || len(release.files) != 0 {
+		t.Error("container and files should be removed")
+	}
+}
@@ -0,0 +1,264 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const scriptsPath = "/v0/scripts"
+
+var (
+	errInvalidScript = errors.New("invalidscript")
+	errNoScript      = errors.New("noscript")
+	errNoScriptRun   = errors.New("noscriptrun")
+	errScriptRunning = errors.New("scriptrunning")
+)
+
+// scriptRun describes a script and its last run.
+type scriptRun struct {
+	Name      string     `json:"name"`
+	Command   string     `json:"command"`
+	Version   string     `json:"version,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	Duration  *string    `json:"duration,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type dataScripts struct {
+	Scripts []scriptRun `json:"scripts"`
+}
+
+// scripts runs the maintenance scripts of the configuration, e.g. to seed
+// the database or clear a cache, on demand against the live version. A
+// script runs in a worktree of the commit of the version once the install
+// step of the deploy has run, with the variables of its deploy, including
+// ${artifact}, and ${port}. Its output is kept until the next run.
+type scripts struct {
+	sync.Mutex
+	log     logr.Logger
+	keys    map[string]execStruct
+	install execStruct
+	git     gitCommand
+	state   *stateManager
+	secrets *secrets
+	execdir string
+	runs    map[string]scriptRun
+}
+
+// checkScripts checks the names of the scripts can be used in the routes and
+// the log files.
+func checkScripts(conf map[string]execStruct) error {
+	for k, v := range conf {
+		if k == "" || nonContainerChars.MatchString(k) || v.Command == "" {
+			return errInvalidScript
+		}
+	}
+	return nil
+}
+
+func newScripts(log logr.Logger, conf *config, s *secrets, git gitCommand, state *stateManager) (*scripts, error) {
+	if err := checkScripts(conf.Scripts); err != nil {
+		return nil, err
+	}
+	keys := map[string]execStruct{}
+	for k, v := range conf.Scripts {
+		v.log = log
+		v.name = k
+		v.runtime = conf.Main.Runtime
+		v.secrets = s
+		keys[k] = v
+	}
+	install := conf.Deploy.Install
+	install.log = log
+	install.name = "install"
+	install.runtime = conf.Main.Runtime
+	install.secrets = s
+	output := &scripts{
+		log:     log,
+		keys:    keys,
+		install: install,
+		git:     git,
+		state:   state,
+		secrets: s,
+		runs:    map[string]scriptRun{},
+	}
+	if git != nil {
+		output.execdir = git.getExecdir()
+	}
+	return output, nil
+}
+
+func (s *scripts) logfile(name string) string {
+	return path.Join(s.execdir, fmt.Sprintf("script-%s.out", name))
+}
+
+// live returns the variables of the live version and its port.
+func (s *scripts) live() (envVars, error) {
+	output, err := s.state.state.getLive()
+	if err != nil {
+		return nil, err
+	}
+	live := liveVersion{}
+	if err := json.Unmarshal(output, &live); err != nil {
+		return nil, err
+	}
+	envs, err := s.state.state.getEnvs(live.Version)
+	if err != nil {
+		return nil, err
+	}
+	_, port, err := net.SplitHostPort(live.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	return envs.merge(envVar{Name: "port", Value: port}), nil
+}
+
+// run starts the script in the background, one run of a script at a time.
+func (s *scripts) run(name string) error {
+	if s == nil {
+		return errNoScript
+	}
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.keys[name]
+	if !ok {
+		return errNoScript
+	}
+	if s.runs[name].Status == runnerStatusStarted {
+		return errScriptRunning
+	}
+	envs, err := s.live()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	s.runs[name] = scriptRun{
+		Name:      name,
+		Command:   e.Command,
+		Version:   envs.get("version"),
+		Status:    runnerStatusStarted,
+		StartTime: &start,
+	}
+	go s.execute(deepCopy(e), deepCopy(s.install), envs)
+	return nil
+}
+
+// execute runs the script and stores its output.
+func (s *scripts) execute(e, install execStruct, envs envVars) {
+	start := time.Now()
+	output, err := s.command(&e, &install, envs)
+	duration := fmt.Sprintf("%dms", time.Since(start).Milliseconds())
+	if err := os.WriteFile(s.logfile(e.name), output, 0644); err != nil {
+		s.log.Error(err, "could not store the output of the script", "data", e.name)
+	}
+	s.Lock()
+	defer s.Unlock()
+	run := s.runs[e.name]
+	run.Status = runnerStatusDone
+	run.Duration = &duration
+	if err != nil {
+		s.log.Error(err, "script has failed", "data", e.name)
+		run.Status = runnerStatusFailed
+		run.Error = s.secrets.mask(err.Error())
+	}
+	s.runs[e.name] = run
+}
+
+// command checks out the version, runs the install step and the script.
+func (s *scripts) command(e, install *execStruct, envs envVars) ([]byte, error) {
+	dir, err := s.git.addWorktree("script-"+e.name, envs.get("commit_sha"))
+	if err != nil {
+		return nil, err
+	}
+	defer s.git.removeWorktree(dir)
+	envs = envs.merge(envVar{Name: "workspace", Value: dir})
+	output := []byte{}
+	for _, v := range []*execStruct{install, e} {
+		if v.Command == "" {
+			continue
+		}
+		cmd, err := v.prepare(dir, envs)
+		if err != nil {
+			return output, err
+		}
+		result, err := cmd.CombinedOutput()
+		output = append(output, result...)
+		if err != nil {
+			return output, err
+		}
+	}
+	return output, nil
+}
+
+// list returns the scripts sorted by name with their last run.
+func (s *scripts) list() []byte {
+	output := dataScripts{Scripts: []scriptRun{}}
+	if s != nil {
+		s.Lock()
+		for k, v := range s.keys {
+			run, ok := s.runs[k]
+			if !ok {
+				run = scriptRun{Name: k, Command: v.Command}
+			}
+			output.Scripts = append(output.Scripts, run)
+		}
+		s.Unlock()
+	}
+	sort.Slice(output.Scripts, func(i, j int) bool {
+		return output.Scripts[i].Name < output.Scripts[j].Name
+	})
+	b, _ := json.Marshal(output)
+	return b
+}
+
+// get returns the script and its last run.
+func (s *scripts) get(name string) ([]byte, error) {
+	if s == nil {
+		return nil, errNoScript
+	}
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.keys[name]
+	if !ok {
+		return nil, errNoScript
+	}
+	run, ok := s.runs[name]
+	if !ok {
+		run = scriptRun{Name: name, Command: e.Command}
+	}
+	return json.Marshal(run)
+}
+
+// logs returns the output of the last run of the script once it is done.
+func (s *scripts) logs(name string) ([]byte, error) {
+	if s == nil {
+		return nil, errNoScript
+	}
+	s.Lock()
+	_, ok := s.keys[name]
+	run, done := s.runs[name]
+	s.Unlock()
+	if !ok {
+		return nil, errNoScript
+	}
+	if !done || run.Status == runnerStatusStarted {
+		return nil, errNoScriptRun
+	}
+	f := &file{filename: s.logfile(name)}
+	output, err := f.ReadLines(0, maxLogLines)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s.secrets.mask(strings.Join(output, "\n"))), nil
+}
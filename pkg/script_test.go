@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+// scriptGitCommand checks out the worktrees in a temporary directory.
+type scriptGitCommand struct {
+	mockGitSuccessCommand
+	dir string
+}
+
+func (git *scriptGitCommand) getExecdir() string {
+	return git.dir
+}
+
+func (git *scriptGitCommand) addWorktree(name, sha string) (string, error) {
+	return git.dir, nil
+}
+
+func newTestScripts(t *testing.T, conf *config) (*scripts, func()) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	s, err := newScripts(&log.MockLogger{}, conf, nil, &scriptGitCommand{dir: dir}, &stateManager{state: &mockState{}})
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+// wait returns the last run of the script once it is done.
+func wait(s *scripts, name string) scriptRun {
+	run := scriptRun{}
+	for i := 0; i < 100; i++ {
+		output, _ := s.get(name)
+		json.Unmarshal(output, &run)
+		if run.Status != runnerStatusStarted {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return run
+}
+
+func Test_newScripts(t *testing.T) {
+	for _, v := range []map[string]execStruct{
+		{"seed db": {Command: "seed"}},
+		{"../seed": {Command: "seed"}},
+		{"seed": {}},
+	} {
+		if err := checkScripts(v); err != errInvalidScript {
+			t.Error("should fail with errInvalidScript, current:", v, err)
+		}
+	}
+}
+
+func Test_scripts_run(t *testing.T) {
+	s, clean := newTestScripts(t, &config{Scripts: map[string]execStruct{
+		"seed-db": {Command: "sh", Args: []string{"-c", "echo seeding ${version} on ${port}"}},
+		"migrate": {Command: "sh", Args: []string{"-c", "echo migrating; exit 1"}},
+	}})
+	defer clean()
+	if _, err := s.logs("seed-db"); err != errNoScriptRun {
+		t.Error("should fail with errNoScriptRun, error:", err)
+	}
+	if err := s.run("seed-db"); err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	run := wait(s, "seed-db")
+	if run.Status != runnerStatusDone || run.Version != "123" || run.Duration == nil {
+		t.Error("script should succeed, current:", run)
+	}
+	output, err := s.logs("seed-db")
+	if err != nil || string(output) != "seeding 123 on 8090" {
+		t.Error("should store the output, current:", string(output), err)
+	}
+	if err := s.run("migrate"); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if run := wait(s, "migrate"); run.Status != runnerStatusFailed || run.Error != "exit status 1" {
+		t.Error("script should fail, current:", run)
+	}
+	output, _ = s.logs("migrate")
+	if string(output) != "migrating" {
+		t.Error("should store the output of the failure, current:", string(output))
+	}
+	if err := s.run("unknown"); err != errNoScript {
+		t.Error("should fail with errNoScript, error:", err)
+	}
+}
+
+func Test_scripts_run_after_install(t *testing.T) {
+	s, clean := newTestScripts(t, &config{
+		Deploy: deployStruct{Install: execStruct{Command: "sh", Args: []string{"-c", "echo installing; touch installed"}}},
+		Scripts: map[string]execStruct{
+			"seed-db": {Command: "sh", Args: []string{"-c", "test -f installed && echo seeding"}},
+		},
+	})
+	defer clean()
+	if err := s.run("seed-db"); err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if run := wait(s, "seed-db"); run.Status != runnerStatusDone {
+		t.Error("script should run once installed, current:", run)
+	}
+	if output, _ := s.logs("seed-db"); string(output) != "installing\nseeding" {
+		t.Error("should store the output of the install and the script, current:", string(output))
+	}
+}
+
+func Test_scripts_running(t *testing.T) {
+	s, clean := newTestScripts(t, &config{Scripts: map[string]execStruct{
+		"clear-cache": {Command: "sleep", Args: []string{"1"}},
+	}})
+	defer clean()
+	if err := s.run("clear-cache"); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if err := s.run("clear-cache"); err != errScriptRunning {
+		t.Error("should fail with errScriptRunning, error:", err)
+	}
+	wait(s, "clear-cache")
+}
+
+func Test_scriptHandler(t *testing.T) {
+	s, clean := newTestScripts(t, &config{Scripts: map[string]execStruct{
+		"seed-db": {Command: "sh", Args: []string{"-c", "echo seeding"}},
+	}})
+	defer clean()
+	mux := newAPI(&stateManager{state: &mockState{}},
+		apiRoute{pattern: scriptsPath, handler: &scriptHandler{scripts: s}},
+		apiRoute{pattern: scriptsPath + "/", handler: &scriptHandler{scripts: s}},
+	)
+	for _, v := range []sample{
+		{name: "list_scripts", method: http.MethodGet, route: "/v0/scripts", status: http.StatusOK, output: `{"scripts":[{"name":"seed-db","command":"sh"}]}`},
+		{name: "get_script", method: http.MethodGet, route: "/v0/scripts/seed-db", status: http.StatusOK, output: `{"name":"seed-db","command":"sh"}`},
+		{name: "get_unknown_script", method: http.MethodGet, route: "/v0/scripts/unknown", status: http.StatusNotFound, output: `{"message":"not found"}`},
+		{name: "get_log_before_run", method: http.MethodGet, route: "/v0/scripts/seed-db/log", status: http.StatusNotFound, output: `{"message":"not found"}`},
+		{name: "run_unknown_script", method: http.MethodPost, route: "/v0/scripts/unknown", status: http.StatusNotFound, output: `{"message":"not found"}`},
+		{name: "run_script", method: http.MethodPost, route: "/v0/scripts/seed-db", status: http.StatusAccepted, output: `{"message":"started"}`},
+		{name: "delete_script", method: http.MethodDelete, route: "/v0/scripts/seed-db", status: http.StatusMethodNotAllowed, output: `{"message":"method not allowed"}`},
+	} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(v.method, v.route, nil))
+		if recorder.Code != v.status || recorder.Body.String() != v.output {
+			t.Errorf("%s: expect %d %s, get: %d %s", v.name, v.status, v.output, recorder.Code, recorder.Body.String())
+		}
+	}
+	wait(s, "seed-db")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v0/scripts/seed-db/log", nil))
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != "seeding" {
+		t.Error("should return the output, current:", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,292 @@
+package pkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SecretKeyEnv is the environment variable that holds the key of the
+	// encrypted secrets file.
+	SecretKeyEnv = "CRZY_SECRET_KEY"
+	// DefaultSecretsFile is the encrypted secrets file.
+	DefaultSecretsFile = "crzy.secrets"
+	maskedValue        = "***"
+)
+
+var (
+	errMissingSecret   = errors.New("missingsecret")
+	errMissingKey      = errors.New("missingkey")
+	errInvalidSecrets  = errors.New("invalidsecrets")
+	errSecretNoSource  = errors.New("secretnosource")
+	errSecretTwoSource = errors.New("secrettwosources")
+)
+
+type secretsStruct struct {
+	File   string         `yaml:"file"`
+	Values []secretStruct `yaml:"values"`
+}
+
+// secretStruct defines where a secret is read from: an environment
+// variable, a file or the encrypted secrets file.
+type secretStruct struct {
+	Name      string `yaml:"name"`
+	Env       string `yaml:"env"`
+	File      string `yaml:"file"`
+	Encrypted bool   `yaml:"encrypted"`
+}
+
+// secrets are injected in the steps and the release but their values are
+// masked in the state, the API and the logs.
+type secrets struct {
+	values envVars
+}
+
+func loadSecrets(conf secretsStruct) (*secrets, error) {
+	s := &secrets{values: envVars{}}
+	var encrypted map[string]string
+	for _, v := range conf.Values {
+		sources := 0
+		for _, source := range []bool{v.Env != "", v.File != "", v.Encrypted} {
+			if source {
+				sources++
+			}
+		}
+		switch {
+		case v.Name == "" || sources == 0:
+			return nil, errSecretNoSource
+		case sources > 1:
+			return nil, errSecretTwoSource
+		}
+		value := ""
+		switch {
+		case v.Env != "":
+			value = os.Getenv(v.Env)
+		case v.File != "":
+			content, err := os.ReadFile(v.File)
+			if err != nil {
+				return nil, err
+			}
+			value = strings.TrimRight(string(content), "\r\n")
+		default:
+			if encrypted == nil {
+				values, err := readSecrets(conf.File)
+				if err != nil {
+					return nil, err
+				}
+				encrypted = values
+			}
+			value = encrypted[v.Name]
+		}
+		if value == "" {
+			return nil, errMissingSecret
+		}
+		s.values.addOne(v.Name, value)
+	}
+	return s, nil
+}
+
+// envs returns the secrets as variables.
+func (s *secrets) envs() envVars {
+	if s == nil {
+		return envVars{}
+	}
+	return newEnvVars(s.values...)
+}
+
+// longest returns the values of the secrets from the longest to the shortest
+// so that a secret that contains another one is masked as a whole.
+func (s *secrets) longest() []string {
+	output := []string{}
+	for _, v := range s.values {
+		output = append(output, v.Value)
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return len(output[i]) > len(output[j])
+	})
+	return output
+}
+
+// mask replaces the values of the secrets in a string.
+func (s *secrets) mask(input string) string {
+	if s == nil {
+		return input
+	}
+	for _, v := range s.longest() {
+		input = strings.ReplaceAll(input, v, maskedValue)
+	}
+	return input
+}
+
+// maskJSON replaces the values of the secrets in a JSON document, including
+// when they are escaped.
+func (s *secrets) maskJSON(input []byte) []byte {
+	if s == nil {
+		return input
+	}
+	output := string(input)
+	for _, v := range s.longest() {
+		escaped, _ := json.Marshal(v)
+		output = strings.ReplaceAll(output, string(escaped[1:len(escaped)-1]), maskedValue)
+	}
+	return []byte(output)
+}
+
+// inject adds the secrets that are not already defined to the variables.
+func (s *secrets) inject(envs envVars) envVars {
+	output := newEnvVars(envs...)
+	names := map[string]bool{}
+	for _, v := range envs {
+		names[v.Name] = true
+	}
+	for _, v := range s.envs() {
+		if !names[v.Name] {
+			output.add(v)
+		}
+	}
+	return output
+}
+
+func secretsFile(file string) string {
+	if file == "" {
+		return DefaultSecretsFile
+	}
+	return file
+}
+
+func secretKey() ([]byte, error) {
+	key := os.Getenv(SecretKeyEnv)
+	if key == "" {
+		return nil, errMissingKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	return sum[:], nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := secretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readSecrets decrypts the secrets file with the key from CRZY_SECRET_KEY.
+func readSecrets(file string) (map[string]string, error) {
+	content, err := os.ReadFile(secretsFile(file))
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, errInvalidSecrets
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errInvalidSecrets
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errInvalidSecrets
+	}
+	values := map[string]string{}
+	if err := yaml.Unmarshal(plain, &values); err != nil {
+		return nil, errInvalidSecrets
+	}
+	return values, nil
+}
+
+// SetSecret adds or updates a secret in the encrypted secrets file. The file
+// is encrypted with AES-GCM and a key derived from CRZY_SECRET_KEY.
+func SetSecret(file, name, value string) error {
+	values := map[string]string{}
+	if _, err := os.Stat(secretsFile(file)); err == nil {
+		if values, err = readSecrets(file); err != nil {
+			return err
+		}
+	}
+	values[name] = value
+	plain, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	data := gcm.Seal(nonce, nonce, plain, nil)
+	return os.WriteFile(secretsFile(file), []byte(base64.StdEncoding.EncodeToString(data)+"\n"), 0600)
+}
+
+// maskedLogger masks the values of the secrets in the messages and the
+// values of a logger.
+type maskedLogger struct {
+	logr.Logger
+	secrets *secrets
+}
+
+func newMaskedLogger(log logr.Logger, s *secrets) logr.Logger {
+	if s == nil || len(s.values) == 0 {
+		return log
+	}
+	return &maskedLogger{Logger: log, secrets: s}
+}
+
+func (l *maskedLogger) maskValues(keysAndValues []interface{}) []interface{} {
+	output := []interface{}{}
+	for _, v := range keysAndValues {
+		if value, ok := v.(string); ok {
+			v = l.secrets.mask(value)
+		}
+		output = append(output, v)
+	}
+	return output
+}
+
+func (l *maskedLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Logger.Info(l.secrets.mask(msg), l.maskValues(keysAndValues)...)
+}
+
+func (l *maskedLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		if masked := l.secrets.mask(err.Error()); masked != err.Error() {
+			err = errors.New(masked)
+		}
+	}
+	l.Logger.Error(err, l.secrets.mask(msg), l.maskValues(keysAndValues)...)
+}
+
+func (l *maskedLogger) V(level int) logr.Logger {
+	return &maskedLogger{Logger: l.Logger.V(level), secrets: l.secrets}
+}
+
+func (l *maskedLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &maskedLogger{Logger: l.Logger.WithValues(l.maskValues(keysAndValues)...), secrets: l.secrets}
+}
+
+func (l *maskedLogger) WithName(name string) logr.Logger {
+	return &maskedLogger{Logger: l.Logger.WithName(name), secrets: l.secrets}
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+func Test_SetSecret_and_loadSecrets(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "crzy.secrets")
+	os.Setenv(SecretKeyEnv, "key")
+	defer os.Unsetenv(SecretKeyEnv)
+	if err := SetSecret(file, "API_KEY", "s3cr3t"); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	if err := SetSecret(file, "OTHER_KEY", "other"); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	content, _ := os.ReadFile(file)
+	if strings.Contains(string(content), "s3cr3t") {
+		t.Error("secrets file should be encrypted")
+	}
+	os.WriteFile(path.Join(dir, "password"), []byte("passw0rd\n"), 0600)
+	os.Setenv("CRZY_TEST_TOKEN", "t0ken")
+	defer os.Unsetenv("CRZY_TEST_TOKEN")
+	s, err := loadSecrets(secretsStruct{
+		File: file,
+		Values: []secretStruct{
+			{Name: "TOKEN", Env: "CRZY_TEST_TOKEN"},
+			{Name: "PASSWORD", File: path.Join(dir, "password")},
+			{Name: "API_KEY", Encrypted: true},
+		},
+	})
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	envs := s.envs()
+	if envs.get("TOKEN") != "t0ken" || envs.get("PASSWORD") != "passw0rd" || envs.get("API_KEY") != "s3cr3t" {
+		t.Error("should load the secrets, current:", envs)
+	}
+	os.Setenv(SecretKeyEnv, "wrong")
+	if _, err := readSecrets(file); err != errInvalidSecrets {
+		t.Error("should fail with errInvalidSecrets, error:", err)
+	}
+}
+
+func Test_loadSecrets_and_fail(t *testing.T) {
+	data := []struct {
+		name    string
+		secrets secretsStruct
+		err     error
+	}{
+		{name: "no_source", secrets: secretsStruct{Values: []secretStruct{{Name: "A"}}}, err: errSecretNoSource},
+		{name: "two_sources", secrets: secretsStruct{Values: []secretStruct{{Name: "A", Env: "A", File: "a"}}}, err: errSecretTwoSource},
+		{name: "missing", secrets: secretsStruct{Values: []secretStruct{{Name: "A", Env: "CRZY_TEST_UNDEFINED"}}}, err: errMissingSecret},
+		{name: "not_encrypted", secrets: secretsStruct{File: "secret_test.go", Values: []secretStruct{{Name: "A", Encrypted: true}}}, err: errInvalidSecrets},
+	}
+	for _, v := range data {
+		if _, err := loadSecrets(v.secrets); err != v.err {
+			t.Error(v.name, "should fail with", v.err, "error:", err)
+		}
+	}
+}
+
+func Test_secrets_mask(t *testing.T) {
+	s := &secrets{values: envVars{{Name: "TOKEN", Value: `t0"ken`}}}
+	if output := s.mask(`curl -H "token: t0"ken"`); output != `curl -H "token: ***"` {
+		t.Error("should mask the secret, current:", output)
+	}
+	if output := string(s.maskJSON([]byte(`{"args":["t0\"ken"]}`))); output != `{"args":["***"]}` {
+		t.Error("should mask the secret, current:", output)
+	}
+	envs := s.inject(envVars{{Name: "version", Value: "1"}})
+	if envs.get("TOKEN") != `t0"ken` || envs.get("version") != "1" {
+		t.Error("should inject the secret, current:", envs)
+	}
+	s = &secrets{values: envVars{{Name: "SHORT", Value: "abc"}, {Name: "LONG", Value: "abcdef"}}}
+	if output := s.mask("key=abcdef"); output != "key=***" {
+		t.Error("should mask the longest secret first, current:", output)
+	}
+	if output := string(s.maskJSON([]byte(`{"key":"abcdef"}`))); output != `{"key":"***"}` {
+		t.Error("should mask the longest secret first, current:", output)
+	}
+	var empty *secrets
+	if empty.mask("t0ken") != "t0ken" || len(empty.inject(envVars{})) != 0 {
+		t.Error("nil secrets should be ignored")
+	}
+}
+
+func Test_maskedLogger(t *testing.T) {
+	mock := &log.MockLogger{}
+	l := newMaskedLogger(mock, &secrets{values: envVars{{Name: "TOKEN", Value: "t0ken"}}})
+	l.Info("using t0ken")
+	l.Error(errors.New("t0ken rejected"), "with t0ken")
+	if strings.Contains(strings.Join(mock.Logs, " "), "t0ken") || len(mock.Logs) != 2 {
+		t.Error("should mask the secret, current:", mock.Logs)
+	}
+}
+
+func Test_prepare_with_secrets(t *testing.T) {
+	e := &execStruct{
+		log:     &log.MockLogger{},
+		secrets: &secrets{values: envVars{{Name: "TOKEN", Value: "t0ken"}}},
+		Command: "echo",
+		Args:    []string{"${TOKEN}"},
+	}
+	cmd, err := e.prepare(".", envVars{{Name: "version", Value: "1"}})
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if cmd.Args[1] != "t0ken" || !strings.Contains(strings.Join(cmd.Env, " "), "TOKEN=t0ken") {
+		t.Error("should inject the secret, current:", cmd.Args, cmd.Env)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,9 +21,17 @@ const (
 
 const maxLogLines = 10000
 
+const (
+	defaultVersionLimit = 20
+	maxVersionLimit     = 100
+)
+
 type syntheticWorkflow struct {
 	Runners map[string]runner `json:"runners"`
 	Version string            `json:"version"`
+	Commit  *commitInfo       `json:"commit,omitempty"`
+	Created time.Time         `json:"created"`
+	Updated time.Time         `json:"updated"`
 }
 
 type runner struct {
@@ -35,6 +45,8 @@ type step struct {
 	Name      string     `json:"name"`
 	StartTime *time.Time `json:"start_time,omitempty"`
 	Duration  *string    `json:"duration,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	Cache     string     `json:"cache,omitempty"`
 	Variables []envVar   `json:"flow.envs,omitempty"`
 }
 
@@ -43,20 +55,33 @@ type stepEvent struct {
 	workflow       string
 	step           step
 	workflowStatus string
+	commit         *commitInfo
 }
 
 type state interface {
 	getConfiguration() []byte
-	listVersions() []byte
+	listVersions(versionQuery) ([]byte, error)
 	listVersionDetails(string) ([]byte, error)
 	addStep(stepEvent)
-	logVersion(string, string) ([]byte, error)
+	logVersion(string, string, int) ([]byte, error)
+	getLive() ([]byte, error)
+	getEnvs(string) (envVars, error)
 }
 
 type defaultState struct {
 	sync.Mutex
 	configuration *configuration
 	state         map[string]syntheticWorkflow
+	live          *liveVersion
+	secrets       *secrets
+	metrics       *metrics
+}
+
+// liveVersion is the version currently served by the proxy and the
+// upstream it is bound to.
+type liveVersion struct {
+	Version  string `json:"version"`
+	Upstream string `json:"upstream"`
 }
 
 type stateManager struct {
@@ -71,13 +96,37 @@ type stateDefaultClient struct {
 
 type stateClient interface {
 	notifyStep(version, workflow, status string, step step)
+	notifyCommit(version string, commit commitInfo)
 }
 
 type stateMockClient struct {
 }
 
+// versionQuery filters and paginates the list of versions. Cursor is the
+// last version of the previous page.
+type versionQuery struct {
+	Status string
+	Branch string
+	Cursor string
+	Limit  int
+}
+
+// versionSummary describes a version in the list of versions.
+type versionSummary struct {
+	Version   string            `json:"version"`
+	Status    string            `json:"status"`
+	Workflows map[string]string `json:"workflows"`
+	SHA       string            `json:"sha,omitempty"`
+	Author    string            `json:"author,omitempty"`
+	Branch    string            `json:"branch,omitempty"`
+	PushedAt  time.Time         `json:"pushed_at"`
+	Duration  string            `json:"duration"`
+	Live      bool              `json:"live"`
+}
+
 type dataVersion struct {
-	Versions []string `json:"versions"`
+	Versions []versionSummary `json:"versions"`
+	Next     string           `json:"next,omitempty"`
 }
 
 func (a *stateDefaultClient) notifyStep(version, workflow, status string, step step) {
@@ -89,9 +138,19 @@ func (a *stateDefaultClient) notifyStep(version, workflow, status string, step s
 	}
 }
 
+func (a *stateDefaultClient) notifyCommit(version string, commit commitInfo) {
+	a.notifier <- stepEvent{
+		version: version,
+		commit:  &commit,
+	}
+}
+
 func (a *stateMockClient) notifyStep(version, workflow, status string, step step) {
 }
 
+func (a *stateMockClient) notifyCommit(version string, commit commitInfo) {
+}
+
 func (r *defaultContainer) newStateManager() *stateManager {
 	return &stateManager{
 		notifier: make(chan stepEvent),
@@ -99,7 +158,9 @@ func (r *defaultContainer) newStateManager() *stateManager {
 			configuration: &configuration{
 				Head: r.config.Main.Head,
 			},
-			state: map[string]syntheticWorkflow{},
+			state:   map[string]syntheticWorkflow{},
+			secrets: r.secrets,
+			metrics: r.getMetrics(),
 		},
 		log: r.log.WithName("state"),
 	}
@@ -108,13 +169,21 @@ func (r *defaultContainer) newStateManager() *stateManager {
 func (s *defaultState) addStep(stepEvent stepEvent) {
 	s.Lock()
 	defer s.Unlock()
+	now := time.Now()
 	version, ok := s.state[stepEvent.version]
 	if !ok {
 		version = syntheticWorkflow{
 			Runners: map[string]runner{},
 			Version: stepEvent.version,
+			Created: now,
 		}
 	}
+	version.Updated = now
+	if stepEvent.commit != nil {
+		version.Commit = stepEvent.commit
+		s.state[stepEvent.version] = version
+		return
+	}
 	workflow, ok := version.Runners[stepEvent.workflow]
 	if !ok {
 		workflow = runner{
@@ -124,22 +193,108 @@ func (s *defaultState) addStep(stepEvent stepEvent) {
 		}
 	}
 	workflow.Status = stepEvent.workflowStatus
+	stepEvent.step.Status = stepEvent.workflowStatus
+	if stepEvent.workflow == "release" && stepEvent.step.Name == "switch" &&
+		stepEvent.workflowStatus == runnerStatusDone {
+		variables := envVars(stepEvent.step.Variables)
+		s.live = &liveVersion{
+			Version:  stepEvent.version,
+			Upstream: variables.get("upstream"),
+		}
+		s.metrics.switched(now.Sub(version.Created))
+	}
+	if stepEvent.step.Duration != nil {
+		if d, err := time.ParseDuration(*stepEvent.step.Duration); err == nil {
+			s.metrics.step(stepEvent.workflow, stepEvent.step.Name, stepEvent.workflowStatus, d)
+		}
+	}
 	workflow.Steps = append(workflow.Steps, stepEvent.step)
 	version.Runners[stepEvent.workflow] = workflow
 	s.state[stepEvent.version] = version
 }
 
-func (s *defaultState) listVersions() []byte {
+// status returns the overall status of a version: failure when a workflow
+// has failed, started while a workflow is running and success otherwise.
+func (x syntheticWorkflow) status() string {
+	status := runnerStatusDone
+	for _, v := range x.Runners {
+		switch v.Status {
+		case runnerStatusFailed:
+			return runnerStatusFailed
+		case runnerStatusStarted:
+			status = runnerStatusStarted
+		}
+	}
+	return status
+}
+
+func (s *defaultState) summary(x syntheticWorkflow) versionSummary {
+	summary := versionSummary{
+		Version:   x.Version,
+		Status:    x.status(),
+		Workflows: map[string]string{},
+		PushedAt:  x.Created,
+		Duration:  fmt.Sprintf("%dms", x.Updated.Sub(x.Created).Milliseconds()),
+		Live:      s.live != nil && s.live.Version == x.Version,
+	}
+	for k, v := range x.Runners {
+		summary.Workflows[k] = v.Status
+	}
+	if x.Commit != nil {
+		summary.SHA = x.Commit.SHA
+		summary.Author = x.Commit.Author
+		summary.Branch = x.Commit.Branch
+	}
+	return summary
+}
+
+// listVersions returns the versions, the most recent first, that match the
+// query.
+func (s *defaultState) listVersions(query versionQuery) ([]byte, error) {
 	s.Lock()
 	defer s.Unlock()
+	if query.Limit <= 0 {
+		query.Limit = defaultVersionLimit
+	}
+	if query.Limit > maxVersionLimit {
+		query.Limit = maxVersionLimit
+	}
+	if _, ok := s.state[query.Cursor]; query.Cursor != "" && !ok {
+		return nil, errInvalidCursor
+	}
+	versions := []syntheticWorkflow{}
+	for _, v := range s.state {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].Created.Equal(versions[j].Created) {
+			return versions[i].Version > versions[j].Version
+		}
+		return versions[i].Created.After(versions[j].Created)
+	})
 	data := dataVersion{
-		Versions: []string{},
+		Versions: []versionSummary{},
 	}
-	for k := range s.state {
-		data.Versions = append(data.Versions, k)
+	started := query.Cursor == ""
+	for _, v := range versions {
+		if !started {
+			started = v.Version == query.Cursor
+			continue
+		}
+		summary := s.summary(v)
+		if query.Status != "" && query.Status != summary.Status {
+			continue
+		}
+		if query.Branch != "" && query.Branch != summary.Branch {
+			continue
+		}
+		if len(data.Versions) == query.Limit {
+			data.Next = data.Versions[len(data.Versions)-1].Version
+			break
+		}
+		data.Versions = append(data.Versions, summary)
 	}
-	output, _ := json.Marshal(&data)
-	return output
+	return json.Marshal(&data)
 }
 
 func (s *defaultState) getConfiguration() []byte {
@@ -148,15 +303,44 @@ func (s *defaultState) getConfiguration() []byte {
 	return []byte(`{"head": "main"}`)
 }
 
+func (s *defaultState) getLive() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.live == nil {
+		return []byte{}, errNoLive
+	}
+	return json.Marshal(s.live)
+}
+
+// getEnvs returns the variables of the last deploy step of a version so
+// that it can be released again.
+func (s *defaultState) getEnvs(version string) (envVars, error) {
+	s.Lock()
+	defer s.Unlock()
+	x, ok := s.state[version]
+	if !ok {
+		return nil, errNoVersion
+	}
+	deploy, ok := x.Runners["deploy"]
+	if !ok || deploy.Status != runnerStatusDone || len(deploy.Steps) == 0 {
+		return nil, errNoDeploy
+	}
+	return newEnvVars(deploy.Steps[len(deploy.Steps)-1].Variables...), nil
+}
+
 var (
-	errNoVersion = errors.New("noversion")
-	errNoLogfile = errors.New("nologfile")
-	errWrongFile = errors.New("wrongfile")
+	errNoDeploy      = errors.New("nodeploy")
+	errNoLive        = errors.New("nolive")
+	errNoVersion     = errors.New("noversion")
+	errNoLogfile     = errors.New("nologfile")
+	errWrongFile     = errors.New("wrongfile")
+	errInvalidCursor = errors.New("invalidcursor")
 )
 
 type displayVersion struct {
-	Version   string   `json:"version"`
-	Workflows []runner `json:"workflows"`
+	Version   string      `json:"version"`
+	Commit    *commitInfo `json:"commit,omitempty"`
+	Workflows []runner    `json:"workflows"`
 }
 
 func (s *defaultState) listVersionDetails(version string) ([]byte, error) {
@@ -178,13 +362,19 @@ func (s *defaultState) listVersionDetails(version string) ([]byte, error) {
 	}
 	y := displayVersion{
 		Version:   x.Version,
+		Commit:    x.Commit,
 		Workflows: runners,
 	}
-	_ = &syntheticWorkflow{}
-	return json.Marshal(y)
+	output, err := json.Marshal(y)
+	if err != nil {
+		return nil, err
+	}
+	return s.secrets.maskJSON(output), nil
 }
 
-func (s *defaultState) logVersion(version, file string) ([]byte, error) {
+// logVersion returns at most maxLogLines lines of a release log, starting at
+// the offset line.
+func (s *defaultState) logVersion(version, file string, offset int) ([]byte, error) {
 	s.Lock()
 	defer s.Unlock()
 	key := 0
@@ -207,11 +397,11 @@ func (s *defaultState) logVersion(version, file string) ([]byte, error) {
 		return []byte{}, errNoLogfile
 	}
 	f := x.Runners["release"].Steps[0].execStruct.files[key]
-	output, err := f.ReadLines(0, maxLogLines)
+	output, err := f.ReadLines(offset, maxLogLines)
 	if err != nil {
 		return nil, err
 	}
-	return []byte(strings.Join(output, "\n")), nil
+	return []byte(s.secrets.mask(strings.Join(output, "\n"))), nil
 }
 
 func (w *stateManager) start(ctx context.Context) error {
@@ -2,7 +2,9 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,8 +14,11 @@ import (
 
 type mockState struct{}
 
-func (m *mockState) listVersions() []byte {
-	return []byte(`{"versions": ["123"]}`)
+func (m *mockState) listVersions(query versionQuery) ([]byte, error) {
+	if query.Cursor == "fail" {
+		return nil, errInvalidCursor
+	}
+	return []byte(`{"versions": [{"version":"123"}]}`), nil
 }
 
 func (m *mockState) listVersionDetails(version string) ([]byte, error) {
@@ -27,13 +32,27 @@ func (m *mockState) addStep(stepEvent) {
 
 }
 
-func (s *mockState) logVersion(version, file string) ([]byte, error) {
+func (s *mockState) logVersion(version, file string, offset int) ([]byte, error) {
 	if version == "fail" {
 		return nil, errors.New("error")
 	}
 	return []byte("line1\nline2"), nil
 }
 
+func (s *mockState) getLive() ([]byte, error) {
+	return []byte(`{"version":"123","upstream":"localhost:8090"}`), nil
+}
+
+func (s *mockState) getEnvs(version string) (envVars, error) {
+	if version == "fail" {
+		return nil, errors.New("error")
+	}
+	if version == "deleted" {
+		return envVars{{Name: "version", Value: version}, {Name: "artifact", Value: "/nonexistent/crzy"}}, nil
+	}
+	return envVars{{Name: "version", Value: version}}, nil
+}
+
 func (s *mockState) getConfiguration() []byte {
 	return []byte(`{"head": "main"}`)
 }
@@ -61,6 +80,9 @@ func Test_newStateManager(t *testing.T) {
 			},
 		})
 	}()
+	go func() {
+		stateClient.notifyCommit("123", commitInfo{SHA: "abc", Author: "me", Branch: "main"})
+	}()
 	go func() {
 		stateClient.notifyStep("123", "deploy", runnerStatusDone, step{
 			execStruct: execStruct{
@@ -73,8 +95,15 @@ func Test_newStateManager(t *testing.T) {
 		})
 	}()
 	time.Sleep(200 * time.Millisecond)
-	data := v.state.listVersions()
-	if string(data) != `{"versions":["123"]}` {
+	data, err := v.state.listVersions(versionQuery{})
+	if err != nil {
+		t.Error("should succeed; error:", err)
+	}
+	output := dataVersion{}
+	json.Unmarshal(data, &output)
+	if len(output.Versions) != 1 || output.Versions[0].Version != "123" ||
+		output.Versions[0].SHA != "abc" || output.Versions[0].Status != runnerStatusDone ||
+		output.Versions[0].Workflows["deploy"] != runnerStatusDone {
 		t.Error("should return expected message, current:", string(data))
 	}
 	cancel()
@@ -153,12 +182,180 @@ func Test_logVersion_success(t *testing.T) {
 			},
 		},
 	}
-	_, err := r.logVersion("abc", "log")
+	_, err := r.logVersion("abc", "log", 0)
 	if err != errNoLogfile {
 		t.Error("should fail with errNoVersion; error:", err)
 	}
-	_, err = r.logVersion("abc", "err")
+	_, err = r.logVersion("abc", "err", 0)
 	if err != errNoLogfile {
 		t.Error("should fail with errNoVersion; error:", err)
 	}
 }
+
+func Test_getLive_after_switch(t *testing.T) {
+	r := &defaultState{
+		state: map[string]syntheticWorkflow{},
+	}
+	if _, err := r.getLive(); err != errNoLive {
+		t.Error("should fail with errNoLive; error:", err)
+	}
+	r.addStep(stepEvent{
+		version:        "abc",
+		workflow:       "release",
+		workflowStatus: runnerStatusDone,
+		step: step{
+			Name:      "switch",
+			Variables: envVars{{Name: "upstream", Value: "localhost:8090"}},
+		},
+	})
+	data, err := r.getLive()
+	if err != nil {
+		t.Error("should succeed; error:", err)
+	}
+	if string(data) != `{"version":"abc","upstream":"localhost:8090"}` {
+		t.Error("error, current message is: ", string(data))
+	}
+	if r.state["abc"].Runners["release"].Steps[0].Status != runnerStatusDone {
+		t.Error("step status should be recorded")
+	}
+}
+
+func Test_getEnvs(t *testing.T) {
+	r := &defaultState{
+		state: map[string]syntheticWorkflow{},
+	}
+	if _, err := r.getEnvs("abc"); err != errNoVersion {
+		t.Error("should fail with errNoVersion; error:", err)
+	}
+	r.addStep(stepEvent{
+		version:        "abc",
+		workflow:       "deploy",
+		workflowStatus: runnerStatusFailed,
+		step:           step{Name: "test", Variables: envVars{{Name: "version", Value: "abc"}}},
+	})
+	if _, err := r.getEnvs("abc"); err != errNoDeploy {
+		t.Error("should fail with errNoDeploy; error:", err)
+	}
+	r.addStep(stepEvent{
+		version:        "abc",
+		workflow:       "deploy",
+		workflowStatus: runnerStatusDone,
+		step: step{Name: "build", Variables: envVars{
+			{Name: "version", Value: "abc"},
+			{Name: "artifact", Value: "/execs/go-abc"},
+		}},
+	})
+	envs, err := r.getEnvs("abc")
+	if err != nil || envs.get("artifact") != "/execs/go-abc" {
+		t.Error("should return the variables of the build; error:", err)
+	}
+}
+
+func Test_listVersions_sort_filter_and_paginate(t *testing.T) {
+	now := time.Now()
+	r := &defaultState{
+		state: map[string]syntheticWorkflow{},
+		live:  &liveVersion{Version: "v2"},
+	}
+	for k, v := range []struct {
+		version, branch, status string
+	}{
+		{"v1", "main", runnerStatusDone},
+		{"v2", "main", runnerStatusDone},
+		{"v3", "dev", runnerStatusFailed},
+		{"v4", "main", runnerStatusStarted},
+	} {
+		r.state[v.version] = syntheticWorkflow{
+			Version: v.version,
+			Runners: map[string]runner{"deploy": {Name: "deploy", Status: v.status}},
+			Commit:  &commitInfo{SHA: "sha-" + v.version, Author: "me", Branch: v.branch},
+			Created: now.Add(time.Duration(k) * time.Minute),
+			Updated: now.Add(time.Duration(k)*time.Minute + 12*time.Millisecond),
+		}
+	}
+	data := []struct {
+		name     string
+		query    versionQuery
+		versions []string
+		next     string
+	}{
+		{name: "all", query: versionQuery{}, versions: []string{"v4", "v3", "v2", "v1"}},
+		{name: "status", query: versionQuery{Status: runnerStatusDone}, versions: []string{"v2", "v1"}},
+		{name: "branch", query: versionQuery{Branch: "dev"}, versions: []string{"v3"}},
+		{name: "first_page", query: versionQuery{Limit: 2}, versions: []string{"v4", "v3"}, next: "v3"},
+		{name: "last_page", query: versionQuery{Limit: 2, Cursor: "v3"}, versions: []string{"v2", "v1"}},
+	}
+	for _, v := range data {
+		output, err := r.listVersions(v.query)
+		if err != nil {
+			t.Error(v.name, "should succeed; error:", err)
+		}
+		result := dataVersion{}
+		json.Unmarshal(output, &result)
+		versions := []string{}
+		for _, x := range result.Versions {
+			versions = append(versions, x.Version)
+		}
+		if strings.Join(versions, ",") != strings.Join(v.versions, ",") || result.Next != v.next {
+			t.Error(v.name, "should return", v.versions, v.next, "current:", string(output))
+		}
+	}
+	output, _ := r.listVersions(versionQuery{Branch: "main", Status: runnerStatusDone, Limit: 1})
+	if string(output) != `{"versions":[{"version":"v2","status":"success","workflows":{"deploy":"success"},`+
+		`"sha":"sha-v2","author":"me","branch":"main","pushed_at":`+jsonTime(now.Add(time.Minute))+
+		`,"duration":"12ms","live":true}],"next":"v2"}` {
+		t.Error("should return the summary, current:", string(output))
+	}
+	if _, err := r.listVersions(versionQuery{Cursor: "unknown"}); err != errInvalidCursor {
+		t.Error("should fail with errInvalidCursor; error:", err)
+	}
+}
+
+func jsonTime(t time.Time) string {
+	output, _ := json.Marshal(t)
+	return string(output)
+}
+
+func Test_listVersionsDetails_with_commit(t *testing.T) {
+	r := &defaultState{
+		state: map[string]syntheticWorkflow{},
+	}
+	r.addStep(stepEvent{
+		version: "abc",
+		commit: &commitInfo{
+			SHA:     "0123",
+			Author:  "me",
+			Subject: "fix",
+			Branch:  "main",
+			Files:   []string{"main.go"},
+		},
+	})
+	data, err := r.listVersionDetails("abc")
+	if err != nil {
+		t.Error("should succeed; error:", err)
+	}
+	if string(data) != `{"version":"abc","commit":{"sha":"0123","author":"me","date":"0001-01-01T00:00:00Z",`+
+		`"subject":"fix","branch":"main","files":["main.go"]},"workflows":[]}` {
+		t.Error("error, current message is: ", string(data))
+	}
+}
+
+func Test_listVersionsDetails_masks_secrets(t *testing.T) {
+	r := &defaultState{
+		state:   map[string]syntheticWorkflow{},
+		secrets: &secrets{values: envVars{{Name: "TOKEN", Value: "t0ken"}}},
+	}
+	r.addStep(stepEvent{
+		version:        "abc",
+		workflow:       "deploy",
+		workflowStatus: runnerStatusDone,
+		step: step{
+			execStruct: execStruct{Command: "curl", Args: []string{"-H", "token: t0ken"}},
+			Name:       "test",
+		},
+	})
+	data, err := r.listVersionDetails("abc")
+	if err != nil || strings.Contains(string(data), "t0ken") || !strings.Contains(string(data), `"token: ***"`) {
+		t.Error("should mask the secret, current:", string(data), err)
+	}
+}
@@ -12,6 +12,7 @@ type store struct {
 	repoDir string
 	execDir string
 	workdir string
+	treeDir string
 	log     logr.Logger
 }
 
@@ -24,7 +25,8 @@ func (r *defaultContainer) createStore() (*store, error) {
 	repoDir := path.Join(rootDir, "repository")
 	workDir := path.Join(rootDir, "workspace")
 	execDir := path.Join(rootDir, "execs")
-	for _, dir := range []string{repoDir, execDir, workDir} {
+	treeDir := path.Join(rootDir, "worktrees")
+	for _, dir := range []string{repoDir, execDir, workDir, treeDir} {
 		os.Mkdir(dir, os.ModeDir|os.ModePerm)
 	}
 	log.Info("directory created", "data", rootDir)
@@ -34,6 +36,7 @@ func (r *defaultContainer) createStore() (*store, error) {
 		repoDir: repoDir,
 		rootDir: rootDir,
 		workdir: workDir,
+		treeDir: treeDir,
 	}, nil
 }
 
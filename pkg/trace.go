@@ -0,0 +1,396 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	traceparentHeader  = "traceparent"
+	defaultServiceName = "crzy"
+	spanKindInternal   = 1
+	spanKindServer     = 2
+	spanStatusOK       = 1
+	spanStatusError    = 2
+	spanQueueSize      = 1024
+	spanBatchSize      = 128
+	spanBatchInterval  = 2 * time.Second
+)
+
+var (
+	errInvalidTraceparent = errors.New("invalidtraceparent")
+	errInvalidTracing     = errors.New("invalidtracing")
+	errExportFailed       = errors.New("exportfailed")
+)
+
+// tracingStruct configures the export of the traces with OTLP over HTTP or
+// to a file, one OTLP JSON document per line.
+type tracingStruct struct {
+	Endpoint string `yaml:"endpoint"`
+	File     string `yaml:"file"`
+	Service  string `yaml:"service"`
+}
+
+// spanContext identifies a span and its trace. It is carried by the events
+// from the push to the release and by the traceparent header of the
+// requests.
+type spanContext struct {
+	traceID string
+	spanID  string
+}
+
+func (c spanContext) valid() bool {
+	return len(c.traceID) == 32 && len(c.spanID) == 16
+}
+
+// traceparent formats the context as a W3C traceparent header.
+func (c spanContext) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", c.traceID, c.spanID)
+}
+
+func isHex(value string) bool {
+	_, err := hex.DecodeString(value)
+	return err == nil && strings.Trim(value, "0") != ""
+}
+
+// parseTraceparent reads a W3C traceparent header.
+func parseTraceparent(value string) (spanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return spanContext{}, errInvalidTraceparent
+	}
+	c := spanContext{traceID: strings.ToLower(parts[1]), spanID: strings.ToLower(parts[2])}
+	if !c.valid() || !isHex(c.traceID) || !isHex(c.spanID) {
+		return spanContext{}, errInvalidTraceparent
+	}
+	return c, nil
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// span is an operation of a trace.
+type span struct {
+	tracer     *tracer
+	context    spanContext
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+func (s *span) setAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// spanContext returns the context of the span, empty when tracing is
+// disabled.
+func (s *span) spanContext() spanContext {
+	if s == nil {
+		return spanContext{}
+	}
+	return s.context
+}
+
+// finish ends the span with the error of the operation and exports it.
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	s.tracer.export(s)
+}
+
+type spanExporter interface {
+	export(document []byte) error
+}
+
+// tracer creates the spans and exports them in the background. The
+// finished spans are queued and a single goroutine exports them in batches
+// of spanBatchSize or every spanBatchInterval. Spans are dropped when the
+// queue is full so that a slow exporter does not stall the proxy.
+type tracer struct {
+	dropped   uint64
+	service   string
+	log       logr.Logger
+	exporters []spanExporter
+	spans     chan *span
+	flushes   chan chan struct{}
+	once      sync.Once
+	interval  time.Duration
+}
+
+// newTracer returns nil when no exporter is configured.
+func newTracer(log logr.Logger, conf tracingStruct) (*tracer, error) {
+	t := &tracer{
+		service:  conf.Service,
+		log:      log,
+		spans:    make(chan *span, spanQueueSize),
+		flushes:  make(chan chan struct{}),
+		interval: spanBatchInterval,
+	}
+	if t.service == "" {
+		t.service = defaultServiceName
+	}
+	if conf.Endpoint != "" {
+		u, err := url.Parse(conf.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errInvalidTracing
+		}
+		t.exporters = append(t.exporters, &otlpExporter{
+			endpoint: conf.Endpoint,
+			client:   &http.Client{Timeout: 5 * time.Second},
+		})
+	}
+	if conf.File != "" {
+		t.exporters = append(t.exporters, &fileExporter{filename: conf.File})
+	}
+	if len(t.exporters) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// start creates a span, child of the parent when it is valid or the root of
+// a new trace otherwise.
+func (t *tracer) start(parent spanContext, name string, kind int) *span {
+	if t == nil {
+		return nil
+	}
+	s := &span{
+		tracer:     t,
+		context:    spanContext{traceID: parent.traceID, spanID: randomID(8)},
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+	if parent.valid() {
+		s.parentID = parent.spanID
+	} else {
+		s.context.traceID = randomID(16)
+	}
+	return s
+}
+
+// export queues the span, the exporter is started with the first one so
+// that validating the configuration does not start it.
+func (t *tracer) export(s *span) {
+	t.once.Do(func() { go t.run() })
+	select {
+	case t.spans <- s:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// run exports the queued spans by batch.
+func (t *tracer) run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	batch := []*span{}
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) < spanBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case done := <-t.flushes:
+			batch = t.drain(batch)
+			t.send(batch)
+			batch = []*span{}
+			close(done)
+			continue
+		}
+		t.send(batch)
+		batch = []*span{}
+	}
+}
+
+// drain adds the spans still in the queue to the batch.
+func (t *tracer) drain(batch []*span) []*span {
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+		default:
+			return batch
+		}
+	}
+}
+
+func (t *tracer) send(batch []*span) {
+	if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+		t.log.Info("span queue full, spans dropped", "data", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	document, err := json.Marshal(t.otlp(batch))
+	if err != nil {
+		t.log.Error(err, "could not encode spans", "data", len(batch))
+		return
+	}
+	for _, v := range t.exporters {
+		if err := v.export(document); err != nil {
+			t.log.Error(err, "could not export spans", "data", len(batch))
+		}
+	}
+}
+
+// flush exports the queued spans and waits for them.
+func (t *tracer) flush() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() { go t.run() })
+	done := make(chan struct{})
+	t.flushes <- done
+	<-done
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := []string{}
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	output := []otlpAttribute{}
+	for _, k := range keys {
+		output = append(output, otlpAttribute{Key: k, Value: otlpValue{StringValue: attributes[k]}})
+	}
+	return output
+}
+
+// otlp converts the spans into the OTLP JSON encoding.
+func (t *tracer) otlp(spans []*span) otlpTraces {
+	scope := otlpScopeSpans{Spans: []otlpSpan{}}
+	for _, s := range spans {
+		status := otlpStatus{Code: spanStatusOK}
+		if s.err != nil {
+			status = otlpStatus{Code: spanStatusError, Message: s.err.Error()}
+		}
+		scope.Spans = append(scope.Spans, otlpSpan{
+			TraceID:           s.context.traceID,
+			SpanID:            s.context.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: fmt.Sprintf("%d", s.start.UnixNano()),
+			EndTimeUnixNano:   fmt.Sprintf("%d", s.end.UnixNano()),
+			Attributes:        otlpAttributes(s.attributes),
+			Status:            status,
+		})
+	}
+	scope.Scope.Name = defaultServiceName
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = otlpAttributes(map[string]string{"service.name": t.service})
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{resource}}
+}
+
+// otlpExporter posts the spans to an OTLP/HTTP endpoint, e.g.
+// http://localhost:4318/v1/traces.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (e *otlpExporter) export(document []byte) error {
+	response, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(document))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", errExportFailed, response.Status)
+	}
+	return nil
+}
+
+// fileExporter appends the spans to a file, mostly to test the traces.
+type fileExporter struct {
+	sync.Mutex
+	filename string
+}
+
+func (e *fileExporter) export(document []byte) error {
+	e.Lock()
+	defer e.Unlock()
+	f, err := os.OpenFile(e.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(document, '\n'))
+	return err
+}
@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+func Test_parseTraceparent(t *testing.T) {
+	c, err := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil || c.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || c.spanID != "00f067aa0ba902b7" {
+		t.Error("should parse the header, current:", c, err)
+	}
+	if c.traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Error("should format the header, current:", c.traceparent())
+	}
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-xxf067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := parseTraceparent(v); err != errInvalidTraceparent {
+			t.Errorf("%q should be invalid, error: %v", v, err)
+		}
+	}
+}
+
+func Test_tracer_nil(t *testing.T) {
+	tr, err := newTracer(&log.MockLogger{}, tracingStruct{})
+	if err != nil || tr != nil {
+		t.Error("should disable tracing without exporter", err)
+	}
+	s := tr.start(spanContext{}, "step", spanKindInternal)
+	s.setAttribute("key", "value")
+	s.finish(nil)
+	tr.flush()
+	if s.spanContext().valid() {
+		t.Error("should not have a context")
+	}
+	if _, err := newTracer(&log.MockLogger{}, tracingStruct{Endpoint: "localhost:4318"}); err != errInvalidTracing {
+		t.Error("should fail with errInvalidTracing, error:", err)
+	}
+}
+
+func readSpans(t *testing.T, filename string) []otlpSpan {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Error("should read the spans, error:", err)
+		t.FailNow()
+	}
+	spans := []otlpSpan{}
+	for _, v := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		traces := otlpTraces{}
+		if err := json.Unmarshal([]byte(v), &traces); err != nil {
+			t.Error("should be OTLP JSON, error:", err)
+			t.FailNow()
+		}
+		spans = append(spans, traces.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+	return spans
+}
+
+func Test_tracer_file(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "traces.json")
+	tr, err := newTracer(&log.MockLogger{}, tracingStruct{File: filename})
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	root := tr.start(spanContext{}, "git.receive", spanKindServer)
+	root.finish(nil)
+	tr.flush()
+	child := tr.start(root.spanContext(), "deploy.build", spanKindInternal)
+	child.setAttribute("crzy.version", "abc")
+	child.finish(errors.New("exit status 1"))
+	tr.flush()
+	spans := readSpans(t, filename)
+	if len(spans) != 2 {
+		t.Error("should export 2 spans, current:", spans)
+		t.FailNow()
+	}
+	if spans[1].TraceID != spans[0].TraceID || spans[1].ParentSpanID != spans[0].SpanID {
+		t.Error("should be a child of the root span, current:", spans)
+	}
+	if spans[1].Status.Code != spanStatusError || len(spans[1].Attributes) != 1 ||
+		spans[1].Attributes[0].Value.StringValue != "abc" {
+		t.Error("should export the status and the attributes, current:", spans[1])
+	}
+}
+
+func Test_tracer_batch_and_drop(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "traces.json")
+	tr, _ := newTracer(&log.MockLogger{}, tracingStruct{File: filename})
+	tr.spans = make(chan *span, 3)
+	tr.once.Do(func() {})
+	for _, v := range []string{"install", "test", "build", "dropped"} {
+		tr.start(spanContext{}, "deploy."+v, spanKindInternal).finish(nil)
+	}
+	if atomic.LoadUint64(&tr.dropped) != 1 {
+		t.Error("should drop the span when the queue is full, current:", tr.dropped)
+	}
+	go tr.run()
+	tr.flush()
+	content, _ := os.ReadFile(filename)
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 1 {
+		t.Error("should export the spans in one batch, current:", len(lines))
+	}
+	if spans := readSpans(t, filename); len(spans) != 3 || spans[2].Name != "deploy.build" {
+		t.Error("should export the queued spans, current:", spans)
+	}
+}
+
+func Test_tracer_otlp(t *testing.T) {
+	documents := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		documents <- b
+	}))
+	defer server.Close()
+	tr, err := newTracer(&log.MockLogger{}, tracingStruct{Endpoint: server.URL + "/v1/traces", Service: "myapi"})
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	tr.start(spanContext{}, "trigger.version", spanKindInternal).finish(nil)
+	tr.flush()
+	traces := otlpTraces{}
+	json.Unmarshal(<-documents, &traces)
+	if len(traces.ResourceSpans) != 1 || traces.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "myapi" ||
+		traces.ResourceSpans[0].ScopeSpans[0].Spans[0].Name != "trigger.version" {
+		t.Error("should post the span, current:", traces)
+	}
+}
+
+type tracedUpstream struct {
+	mockUpstream
+	host string
+}
+
+func (u *tracedUpstream) getDefault() (liveVersion, error) {
+	return liveVersion{Version: "123", Upstream: u.host}, nil
+}
+
+func Test_newReverseProxy_and_trace(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "traces.json")
+	received := ""
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(traceparentHeader)
+	}))
+	defer backend.Close()
+	r := &defaultContainer{
+		log:    &log.MockLogger{},
+		config: &config{Main: mainStruct{Tracing: tracingStruct{File: filename}}},
+	}
+	server := httptest.NewServer(r.newReverseProxy(&tracedUpstream{host: strings.TrimPrefix(backend.URL, "http://")}))
+	defer server.Close()
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/color", nil)
+	request.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	response.Body.Close()
+	r.getTracer().flush()
+	spans := readSpans(t, filename)
+	if len(spans) != 1 || spans[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spans[0].ParentSpanID != "00f067aa0ba902b7" {
+		t.Error("should continue the trace of the request, current:", spans)
+		t.FailNow()
+	}
+	if received != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+spans[0].SpanID+"-01" {
+		t.Error("should propagate the span to the release, current:", received)
+	}
+	attributes := map[string]string{}
+	for _, v := range spans[0].Attributes {
+		attributes[v.Key] = v.Value.StringValue
+	}
+	if attributes["crzy.version"] != "123" || attributes["http.status_code"] != "200" || attributes["http.target"] != "/color" {
+		t.Error("should record the version and the response, current:", attributes)
+	}
+}
+
+func Test_captureAndTrigger_and_trace(t *testing.T) {
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	tr, _ := newTracer(&log.MockLogger{}, tracingStruct{File: path.Join(dir, "traces.json")})
+	action := make(chan event, 1)
+	g := &gitServer{
+		action:   action,
+		repoName: "color.git",
+		tracer:   tr,
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	server := httptest.NewServer(g.captureAndTrigger(next))
+	defer server.Close()
+	response, err := server.Client().Post(server.URL+"/color.git/git-receive-pack", "application/x-git-receive-pack-request", nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	response.Body.Close()
+	val := <-action
+	tr.flush()
+	spans := readSpans(t, path.Join(dir, "traces.json"))
+	if !val.trace.valid() || len(spans) != 1 || spans[0].Name != "git.receive" || spans[0].SpanID != val.trace.spanID {
+		t.Error("should start the trace of the push, current:", val.trace, spans)
+	}
+}
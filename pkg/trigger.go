@@ -5,25 +5,33 @@ import (
 	"errors"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 )
 
-var errWrongVersionOutput error = errors.New("wrongversion")
+var (
+	errWrongVersionOutput error = errors.New("wrongversion")
+	errWrongCommitOutput  error = errors.New("wrongcommit")
+)
 
 type triggerWorkflow struct {
 	triggerStruct
-	head    string
-	log     logr.Logger
-	git     gitCommand
-	command triggerCommand
-	state   stateClient
+	head      string
+	log       logr.Logger
+	git       gitCommand
+	command   triggerCommand
+	state     stateClient
+	notifiers *notifiers
+	metrics   *metrics
+	tracer    *tracer
 }
 
 func (w *triggerWorkflow) start(ctx context.Context, action <-chan event, deploy chan<- event) error {
 	log := w.log.WithName("trigger")
 	deploying := false
 	triggered := false
+	trace := spanContext{}
 	command := w.command
 	command.setTriggerWorkflow(w)
 	for {
@@ -33,45 +41,31 @@ func (w *triggerWorkflow) start(ctx context.Context, action <-chan event, deploy
 			case triggeredMessage:
 				log.Info("starting trigger...")
 				triggered = true
+				trace = action.trace
 				if !deploying {
 					triggered = false
-					err := w.git.syncWorkspace(w.head)
+					envs, err := w.prepare(log, command, trace)
 					if err != nil {
-						log.Error(err, "error during sync of the repository")
 						continue
 					}
-					version, err := command.version()
-					if err != nil {
-						log.Error(err, "error during version of the repository")
-						continue
-					}
-					w.state.notifyStep(
-						version, "trigger",
-						runnerStatusDone,
-						step{execStruct: execStruct{Command: "version"}, Name: "version"})
-					// TODO: check the version does not exist yet, if it does not kick off the deploy
-					log.Info("version computed, deploying now...", "data", version)
 					deploying = true
-					deploy <- event{id: triggeredMessage, envs: []envVar{{Name: "version", Value: version}}}
+					deploy <- event{id: triggeredMessage, envs: envs, trace: trace}
 				}
 			case deployedMessage:
 				deploying = false
+				if workspace := action.envs.get("workspace"); workspace != "" {
+					if err := w.git.removeWorktree(workspace); err != nil {
+						log.Error(err, "error removing the worktree", "data", workspace)
+					}
+				}
 				if triggered {
 					triggered = false
-					err := w.git.syncWorkspace(w.head)
+					envs, err := w.prepare(log, command, trace)
 					if err != nil {
-						log.Error(err, "error during sync of the repository")
 						continue
 					}
-					version, err := command.version()
-					if err != nil {
-						log.Error(err, "error during version of the repository")
-						continue
-					}
-					// TODO: check the version does not exist yet, if it does not kick off the deploy
-					log.Info("version computed, deploying now...", "data", version)
 					deploying = true
-					deploy <- event{id: triggeredMessage, envs: []envVar{{Name: "version", Value: version}}}
+					deploy <- event{id: triggeredMessage, envs: envs, trace: trace}
 				}
 			}
 		case <-ctx.Done():
@@ -80,8 +74,80 @@ func (w *triggerWorkflow) start(ctx context.Context, action <-chan event, deploy
 	}
 }
 
+// prepare syncs the workspace and computes the variables that identify the
+// version to deploy. The trace is the one of the push.
+func (w *triggerWorkflow) prepare(log logr.Logger, command triggerCommand, trace spanContext) (envs envVars, err error) {
+	w.metrics.trigger()
+	s := w.tracer.start(trace, "trigger.version", spanKindInternal)
+	defer func() { s.finish(err) }()
+	err = w.git.syncWorkspace(w.head)
+	if err != nil {
+		log.Error(err, "error during sync of the repository")
+		return nil, err
+	}
+	version, err := command.version()
+	if err != nil {
+		log.Error(err, "error during version of the repository")
+		return nil, err
+	}
+	tree, err := command.tree()
+	if err != nil {
+		log.Error(err, "error during tree of the repository")
+		return nil, err
+	}
+	commit, err := command.commit()
+	if err != nil {
+		log.Error(err, "error reading the commit of the repository")
+		return nil, err
+	}
+	workspace, err := w.git.addWorktree(version, commit.SHA)
+	if err != nil {
+		log.Error(err, "error creating the worktree", "data", commit.SHA)
+		return nil, err
+	}
+	commit.Branch = w.head
+	s.setAttribute("crzy.version", version)
+	s.setAttribute("crzy.commit_sha", commit.SHA)
+	w.notifiers.notify(notification{
+		Event:   eventPushReceived,
+		Version: version,
+		Branch:  commit.Branch,
+		SHA:     commit.SHA,
+		Author:  commit.Author,
+		Subject: commit.Subject,
+	})
+	w.state.notifyCommit(version, commit)
+	w.state.notifyStep(
+		version, "trigger",
+		runnerStatusDone,
+		step{execStruct: execStruct{Command: "version"}, Name: "version"})
+	// TODO: check the version does not exist yet, if it does not kick off the deploy
+	log.Info("version computed, deploying now...", "data", version)
+	return newEnvVars(
+		envVar{Name: "version", Value: version},
+		envVar{Name: "tree", Value: tree},
+		envVar{Name: "workspace", Value: workspace},
+		envVar{Name: "commit_sha", Value: commit.SHA},
+		envVar{Name: "commit_author", Value: commit.Author},
+		envVar{Name: "branch", Value: commit.Branch},
+		envVar{Name: "commit_subject", Value: commit.Subject},
+	), nil
+}
+
+// commitInfo describes the commit a version is built from.
+type commitInfo struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author,omitempty"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject,omitempty"`
+	Branch  string    `json:"branch,omitempty"`
+	Files   []string  `json:"files,omitempty"`
+}
+
 type triggerCommand interface {
 	version() (string, error)
+	tree() (string, error)
+	commit() (commitInfo, error)
 	setTriggerWorkflow(*triggerWorkflow)
 }
 
@@ -115,3 +181,51 @@ func (d *defaultTriggerCommand) version() (string, error) {
 	result := strings.Split(string(output), "\n")[0]
 	return result, nil
 }
+
+// tree returns the hash of the git tree of the workspace. Two versions with
+// the same tree share the same content and can reuse the same artifact.
+func (d *defaultTriggerCommand) tree() (string, error) {
+	output, err := getCmd(d.trigger.git.getWorkspace(), envVars{}, d.trigger.git.getBin(), "rev-parse", "HEAD^{tree}").CombinedOutput()
+	if err != nil {
+		d.trigger.log.Error(err, "could not get tree")
+		return "", err
+	}
+	return strings.Split(string(output), "\n")[0], nil
+}
+
+// commit returns the SHA, the author, the committer date, the subject and
+// the changed files of the commit of the workspace.
+func (d *defaultTriggerCommand) commit() (commitInfo, error) {
+	workspace, bin := d.trigger.git.getWorkspace(), d.trigger.git.getBin()
+	output, err := getCmd(workspace, envVars{}, bin, "log", "-1", "--format=%H%n%an <%ae>%n%cI%n%s").CombinedOutput()
+	if err != nil {
+		d.trigger.log.Error(err, "could not get commit")
+		return commitInfo{}, err
+	}
+	lines := strings.Split(string(output), "\n")
+	if len(lines) < 4 {
+		return commitInfo{}, errWrongCommitOutput
+	}
+	date, err := time.Parse(time.RFC3339, lines[2])
+	if err != nil {
+		return commitInfo{}, err
+	}
+	output, err = getCmd(workspace, envVars{}, bin, "diff-tree", "--root", "--no-commit-id", "--name-only", "-r", "HEAD").CombinedOutput()
+	if err != nil {
+		d.trigger.log.Error(err, "could not get the changed files")
+		return commitInfo{}, err
+	}
+	files := []string{}
+	for _, v := range strings.Split(string(output), "\n") {
+		if v != "" {
+			files = append(files, v)
+		}
+	}
+	return commitInfo{
+		SHA:     lines[0],
+		Author:  lines[1],
+		Date:    date,
+		Subject: lines[3],
+		Files:   files,
+	}, nil
+}
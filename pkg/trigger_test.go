@@ -23,6 +23,14 @@ func (w *mockTriggerCommand) version() (string, error) {
 	return "1", errors.New("error")
 }
 
+func (w *mockTriggerCommand) tree() (string, error) {
+	return "tree", nil
+}
+
+func (w *mockTriggerCommand) commit() (commitInfo, error) {
+	return commitInfo{SHA: "sha", Author: "me <me@example.com>"}, nil
+}
+
 func (d *mockTriggerCommand) setTriggerWorkflow(w *triggerWorkflow) {
 }
 
@@ -47,7 +55,14 @@ func Test_triggerWorkflow_and_succeed(t *testing.T) {
 	if deploy.id != triggeredMessage {
 		t.Error("deploy should start version")
 	}
-	startTrigger <- event{id: deployedMessage}
+	if deploy.envs.get("workspace") != "/worktrees/1" {
+		t.Error("deploy should run in the worktree, current:", deploy.envs.get("workspace"))
+	}
+	if deploy.envs.get("commit_sha") != "sha" || deploy.envs.get("commit_author") != "me <me@example.com>" ||
+		deploy.envs.get("branch") != "main" {
+		t.Error("deploy should get the commit variables, current:", deploy.envs)
+	}
+	startTrigger <- event{id: deployedMessage, envs: deploy.envs}
 	time.Sleep(200 * time.Microsecond)
 	startTrigger <- event{id: triggeredMessage}
 	deploy = <-startDeploy
@@ -182,3 +197,47 @@ func Test_defaultTriggerCommand_with_error(t *testing.T) {
 		t.Error("should return an error with execution", err)
 	}
 }
+
+func Test_defaultTriggerCommand_tree(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	dir := path.Dir(filename)
+	w := &triggerWorkflow{
+		git: &defaultGitCommand{
+			bin: "git",
+			store: store{
+				workdir: dir,
+			},
+			log: &log.MockLogger{},
+		},
+		log:   &log.MockLogger{},
+		state: &stateMockClient{},
+	}
+	command := &defaultTriggerCommand{}
+	command.setTriggerWorkflow(w)
+	x, err := command.tree()
+	if err != nil || len(x) != 40 {
+		t.Error("tree should be 40 length", x, err)
+	}
+}
+
+func Test_defaultTriggerCommand_commit(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	dir := path.Dir(filename)
+	w := &triggerWorkflow{
+		git: &defaultGitCommand{
+			bin: "git",
+			store: store{
+				workdir: dir,
+			},
+			log: &log.MockLogger{},
+		},
+		log:   &log.MockLogger{},
+		state: &stateMockClient{},
+	}
+	command := &defaultTriggerCommand{}
+	command.setTriggerWorkflow(w)
+	x, err := command.commit()
+	if err != nil || len(x.SHA) != 40 || x.Author == "" || x.Date.IsZero() || x.Subject == "" {
+		t.Error("commit should return the SHA, author, date and subject", x, err)
+	}
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultVerifyStatus = "2.."
+	verifyTimeout       = 10 * time.Second
+	verifyBackoff       = time.Second
+	maxVerifyBody       = 1 << 20
+)
+
+var (
+	errInvalidVerify = errors.New("invalidverify")
+	errVerifyFailed  = errors.New("verifyfailed")
+)
+
+// verifyStruct is a check of the new version before the proxy switches to
+// it: either an HTTP probe of a path on its port, with the status and the
+// body matched by regular expressions, or a command that gets ${port}. A
+// failed check is retried after a second.
+type verifyStruct struct {
+	Name       string `yaml:"name"`
+	Path       string `yaml:"path"`
+	Status     string `yaml:"status"`
+	Body       string `yaml:"body"`
+	Retries    int    `yaml:"retries"`
+	execStruct `yaml:",inline"`
+}
+
+// check is a verification with its regular expressions compiled.
+type check struct {
+	verifyStruct
+	status *regexp.Regexp
+	body   *regexp.Regexp
+}
+
+// newChecks validates the checks, each one is a probe or a command.
+func newChecks(conf []verifyStruct, runtime string, s *secrets) ([]check, error) {
+	checks := []check{}
+	for k, v := range conf {
+		if (v.Path == "") == (v.Command == "") {
+			return nil, errInvalidVerify
+		}
+		if v.Name == "" {
+			v.Name = fmt.Sprintf("verify-%d", k+1)
+		}
+		v.name = v.Name
+		v.runtime = runtime
+		v.secrets = s
+		status := v.Status
+		if status == "" {
+			status = defaultVerifyStatus
+		}
+		c := check{verifyStruct: v}
+		var err error
+		if c.status, err = regexp.Compile("^(?:" + status + ")$"); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidVerify, err)
+		}
+		if v.Body != "" {
+			if c.body, err = regexp.Compile(v.Body); err != nil {
+				return nil, fmt.Errorf("%w: %v", errInvalidVerify, err)
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// probe calls the path on the port and matches the response.
+func (c *check) probe(port string, envs envVars) error {
+	route, err := envs.replace(c.Path)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: verifyTimeout}
+	response, err := client.Get("http://localhost:" + port + route)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errVerifyFailed, c.Name, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxVerifyBody))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errVerifyFailed, c.Name, err)
+	}
+	if !c.status.MatchString(strconv.Itoa(response.StatusCode)) {
+		return fmt.Errorf("%w: %s: unexpected status %d", errVerifyFailed, c.Name, response.StatusCode)
+	}
+	if c.body != nil && !c.body.Match(body) {
+		return fmt.Errorf("%w: %s: unexpected body", errVerifyFailed, c.Name)
+	}
+	return nil
+}
+
+// verify runs the checks against the version started on the port. It stops
+// at the first check that fails.
+func (r *releaseWorkflow) verify(port string, envs envVars, trace spanContext) error {
+	log := r.log.WithName("release")
+	w := &workflow{
+		log:       log,
+		version:   envs.get("version"),
+		name:      "release",
+		basedir:   r.execdir,
+		envs:      envs,
+		state:     r.state,
+		tracer:    r.tracer,
+		trace:     trace,
+		notifiers: r.notifiers,
+	}
+	for _, v := range r.checks {
+		var err error
+		for attempt := 0; attempt <= v.Retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(r.verifyBackoff)
+			}
+			if v.Path == "" {
+				e := deepCopy(v.execStruct)
+				e.log = log
+				_, err = w.execute(&e)
+			} else {
+				err = r.probe(w, v, port)
+			}
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probe runs the HTTP check and records it as a step of the release.
+func (r *releaseWorkflow) probe(w *workflow, c check, port string) error {
+	s := w.startSpan(&c.execStruct)
+	start := time.Now()
+	err := c.probe(port, w.envs)
+	s.finish(err)
+	status := runnerStatusDone
+	if err != nil {
+		status = runnerStatusFailed
+	}
+	duration := fmt.Sprintf("%dms", time.Since(start).Milliseconds())
+	r.state.notifyStep(
+		w.version,
+		w.name,
+		status,
+		step{
+			execStruct: execStruct{Command: "GET", Args: []string{c.Path}},
+			Name:       c.Name,
+			StartTime:  &start,
+			Duration:   &duration,
+			Variables:  w.envs,
+		})
+	return err
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/go-crzy/crzy/logr"
+	"gopkg.in/yaml.v3"
+)
+
+// serverPort returns the port of the test server, the release probed by
+// the checks.
+func serverPort(server *httptest.Server) string {
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return port
+}
+
+func Test_newChecks(t *testing.T) {
+	checks, err := newChecks([]verifyStruct{{Path: "/health"}, {Name: "smoke", execStruct: execStruct{Command: "make"}}}, "", nil)
+	if err != nil || len(checks) != 2 {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if checks[0].Name != "verify-1" || !checks[0].status.MatchString("204") || checks[0].status.MatchString("2040") {
+		t.Error("should default the name and the status, current:", checks[0].Name)
+	}
+	if checks[1].name != "smoke" || checks[1].Command != "make" {
+		t.Error("should keep the command, current:", checks[1])
+	}
+	for _, v := range []verifyStruct{
+		{},
+		{Path: "/health", execStruct: execStruct{Command: "make"}},
+		{Path: "/health", Status: "("},
+		{Path: "/health", Body: "["},
+	} {
+		if _, err := newChecks([]verifyStruct{v}, "", nil); !errors.Is(err, errInvalidVerify) {
+			t.Error("should fail with errInvalidVerify, current:", v, err)
+		}
+	}
+}
+
+func Test_verifyStruct_yaml(t *testing.T) {
+	conf := releaseStruct{}
+	err := yaml.Unmarshal([]byte(`
+verify:
+  - name: health
+    path: /health
+    status: "200"
+    body: up
+  - name: smoke
+    command: ./smoke.sh
+    args: ["${port}"]
+`), &conf)
+	if err != nil || len(conf.Verify) != 2 {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if conf.Verify[0].Path != "/health" || conf.Verify[0].Body != "up" || conf.Verify[1].Command != "./smoke.sh" || conf.Verify[1].Args[0] != "${port}" {
+		t.Error("should parse the checks, current:", conf.Verify)
+	}
+}
+
+func Test_check_probe(t *testing.T) {
+	server, _ := newTestServer(`{"status":"up"}`)
+	port := serverPort(server)
+	defer server.Close()
+	data := []struct {
+		conf verifyStruct
+		err  error
+	}{
+		{conf: verifyStruct{Path: "/health"}, err: nil},
+		{conf: verifyStruct{Path: "/health", Status: "200|204", Body: `"status":"up"`}, err: nil},
+		{conf: verifyStruct{Path: "/${version}/health", Status: "201"}, err: errVerifyFailed},
+		{conf: verifyStruct{Path: "/health", Body: "down"}, err: errVerifyFailed},
+	}
+	for _, v := range data {
+		checks, err := newChecks([]verifyStruct{v.conf}, "", nil)
+		if err != nil {
+			t.Error("should succeed, error:", err)
+			t.FailNow()
+		}
+		if err := checks[0].probe(port, envVars{{Name: "version", Value: "abc"}}); !errors.Is(err, v.err) {
+			t.Errorf("%v: expect %v, get: %v", v.conf, v.err, err)
+		}
+	}
+}
+
+func Test_releaseWorkflow_verify(t *testing.T) {
+	server, _ := newTestServer("starting", http.StatusServiceUnavailable)
+	port := serverPort(server)
+	defer server.Close()
+	state := &mockStateRecorder{}
+	checks, err := newChecks([]verifyStruct{
+		{Name: "port", execStruct: execStruct{Command: "test", Args: []string{"${port}", "=", port}, WorkDir: "."}},
+		{Name: "health", Path: "/health", Retries: 2},
+	}, "", nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	release := &releaseWorkflow{
+		log:           &log.MockLogger{},
+		execdir:       ".",
+		checks:        checks,
+		verifyBackoff: time.Millisecond,
+		state:         state,
+	}
+	err = release.verify(port, envVars{{Name: "version", Value: "abc"}, {Name: "port", Value: port}}, spanContext{})
+	if !errors.Is(err, errVerifyFailed) {
+		t.Error("should fail with errVerifyFailed, error:", err)
+	}
+	if len(state.steps) != 4 || state.steps[0].Name != "port" ||
+		state.steps[3].Name != "health" || state.steps[3].Args[0] != "/health" {
+		t.Error("should record the command and the 3 probes, current:", state.steps)
+	}
+}
+
+func Test_switchProcesses_with_failed_verify(t *testing.T) {
+	server, _ := newTestServer("", http.StatusInternalServerError)
+	port := serverPort(server)
+	defer server.Close()
+	checks, _ := newChecks([]verifyStruct{{Path: "/health"}}, "", nil)
+	dir, err := os.MkdirTemp("", "crzy")
+	if err != nil {
+		t.Error("could not create tmpdir")
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	switched := ""
+	release := &releaseWorkflow{
+		log:            &log.MockLogger{},
+		execdir:        dir,
+		checks:         checks,
+		processes:      map[string]*os.Process{},
+		files:          map[string][]*file{},
+		switchUpstream: func(version, upstream string) { switched = upstream },
+		state:          &stateMockClient{},
+	}
+	command := execStruct{log: &log.MockLogger{}, Command: "sleep", Args: []string{"10"}, WorkDir: "."}
+	err = release.switchProcesses(port, command, envVars{{Name: "version", Value: "abc"}}, spanContext{})
+	if !errors.Is(err, errVerifyFailed) {
+		t.Error("should fail with errVerifyFailed, error:", err)
+	}
+	if switched != "" || len(release.processes) != 0 {
+		t.Error("should stop the version and keep the live one, current:", switched, release.processes)
+	}
+}
@@ -0,0 +1,146 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+const signatureHeader = "X-Crzy-Signature"
+
+var (
+	errInvalidWebhook = errors.New("invalidwebhook")
+	errWebhookFailed  = errors.New("webhookfailed")
+)
+
+// webhookStruct configures a webhook. The url, the headers and the secret
+// can reference the secrets and the environment variables with ${}. Without
+// events, every event is sent. Without retries, the notifiers retry with
+// their default.
+type webhookStruct struct {
+	URL      string            `yaml:"url"`
+	Headers  map[string]string `yaml:"headers"`
+	Template string            `yaml:"template"`
+	Secret   string            `yaml:"secret"`
+	Retries  int               `yaml:"retries"`
+	Events   []string          `yaml:"events"`
+}
+
+// webhookNotifier posts the notifications as JSON to a URL. The body is
+// signed with HMAC-SHA256 when a secret is configured.
+type webhookNotifier struct {
+	url      string
+	headers  map[string]string
+	template *template.Template
+	secret   string
+	retries  int
+	events   map[string]bool
+	client   *http.Client
+}
+
+func newWebhookNotifier(conf webhookStruct, s *secrets) (*webhookNotifier, error) {
+	envs := s.envs()
+	address, err := envs.replace(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errInvalidWebhook
+	}
+	w := &webhookNotifier{
+		url:     address,
+		headers: map[string]string{},
+		retries: conf.Retries,
+		events:  map[string]bool{},
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	for k, v := range conf.Headers {
+		if w.headers[k], err = envs.replace(v); err != nil {
+			return nil, err
+		}
+	}
+	if w.secret, err = envs.replace(conf.Secret); err != nil {
+		return nil, err
+	}
+	if w.template, err = newMessageTemplate(conf.Template); err != nil {
+		return nil, err
+	}
+	for _, v := range conf.Events {
+		w.events[v] = true
+	}
+	return w, nil
+}
+
+// body renders the template with the notification, or encodes it in JSON.
+func (w *webhookNotifier) body(n notification) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(n)
+	}
+	output, err := render(w.template, n)
+	return []byte(output), err
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhookNotifier) post(body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		request.Header.Set(k, v)
+	}
+	if w.secret != "" {
+		request.Header.Set(signatureHeader, sign(w.secret, body))
+	}
+	response, err := w.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", errWebhookFailed, response.Status)
+	}
+	return nil
+}
+
+func (w *webhookNotifier) name() string {
+	return "webhook"
+}
+
+func (w *webhookNotifier) accept(event string) bool {
+	return len(w.events) == 0 || w.events[event]
+}
+
+// attempts is the number of times the notifiers send a notification.
+func (w *webhookNotifier) attempts() int {
+	if w.retries <= 0 {
+		return notificationAttempts
+	}
+	return w.retries + 1
+}
+
+// notify posts the notification, the notifiers retry it on failure.
+func (w *webhookNotifier) notify(n notification) error {
+	if !w.accept(n.Event) {
+		return nil
+	}
+	body, err := w.body(n)
+	if err != nil {
+		return err
+	}
+	return w.post(body)
+}
@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/go-crzy/crzy/logr"
+)
+
+type testRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newTestServer records the requests and answers them with the body and
+// the codes in turn, repeating the last one, 200 by default. It stands for
+// the webhooks, the forges and the probed releases.
+func newTestServer(body string, codes ...int) (*httptest.Server, chan testRequest) {
+	requests := make(chan testRequest, 10)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		select {
+		case requests <- testRequest{path: r.URL.EscapedPath(), header: r.Header, body: b}:
+		default:
+		}
+		if len(codes) > 0 {
+			w.WriteHeader(codes[calls])
+		}
+		if calls < len(codes)-1 {
+			calls++
+		}
+		w.Write([]byte(body))
+	}))
+	return server, requests
+}
+
+func Test_webhookNotifier_json(t *testing.T) {
+	server, requests := newTestServer("")
+	defer server.Close()
+	s := &secrets{values: envVars{{Name: "HOOK_TOKEN", Value: "s3cr3t"}}}
+	w, err := newWebhookNotifier(webhookStruct{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer ${HOOK_TOKEN}"},
+		Secret:  "${HOOK_TOKEN}",
+	}, s)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if err := w.notify(notification{Event: eventDeploySucceeded, Version: "abc", Message: "deployed"}); err != nil {
+		t.Error("should succeed, error:", err)
+	}
+	r := <-requests
+	n := notification{}
+	json.Unmarshal(r.body, &n)
+	if n.Event != eventDeploySucceeded || n.Version != "abc" || n.Message != "deployed" {
+		t.Error("should post the notification, current:", string(r.body))
+	}
+	if r.header.Get("Authorization") != "Bearer s3cr3t" {
+		t.Error("should replace the secret in the headers, current:", r.header.Get("Authorization"))
+	}
+	if r.header.Get(signatureHeader) != sign("s3cr3t", r.body) {
+		t.Error("should sign the body, current:", r.header.Get(signatureHeader))
+	}
+}
+
+func Test_webhookNotifier_template_and_events(t *testing.T) {
+	server, requests := newTestServer("")
+	defer server.Close()
+	w, err := newWebhookNotifier(webhookStruct{
+		URL:      server.URL,
+		Template: `{"text": {{json .Message}}, "version": "{{.Version}}"}`,
+		Events:   []string{eventStepFailed},
+	}, nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	w.notify(notification{Event: eventDeploySucceeded, Version: "abc"})
+	w.notify(notification{Event: eventStepFailed, Version: "abc", Message: `step "test" failed`})
+	r := <-requests
+	if string(r.body) != `{"text": "step \"test\" failed", "version": "abc"}` {
+		t.Error("should render the template, current:", string(r.body))
+	}
+	if len(requests) != 0 {
+		t.Error("should only send the configured events")
+	}
+}
+
+func Test_webhookNotifier_retries(t *testing.T) {
+	server, requests := newTestServer("", http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	defer server.Close()
+	w, err := newWebhookNotifier(webhookStruct{URL: server.URL, Retries: 2}, nil)
+	if err != nil {
+		t.Error("should succeed, error:", err)
+		t.FailNow()
+	}
+	if err := w.notify(notification{Event: eventRollback}); err == nil || len(requests) != 1 {
+		t.Error("should post once and fail, error:", err)
+	}
+	<-requests
+	n := &notifiers{log: &log.MockLogger{}, backends: []notifier{w}, backoff: time.Millisecond}
+	n.notify(notification{Event: eventRollback})
+	output := dataDeliveries{}
+	json.Unmarshal(n.list(), &output)
+	if len(requests) != 2 || output.Notifications[0].Status != deliveryDelivered || output.Notifications[0].Attempts != 2 {
+		t.Error("should be retried by the notifiers, current:", output)
+	}
+	w.retries = 0
+	if w.attempts() != notificationAttempts {
+		t.Error("should default to the attempts of the notifiers, current:", w.attempts())
+	}
+	server.Close()
+	if err := w.notify(notification{Event: eventRollback}); err == nil {
+		t.Error("should fail when the server is down")
+	}
+}
+
+func Test_newWebhookNotifier_and_fail(t *testing.T) {
+	data := []webhookStruct{
+		{URL: "localhost:8000"},
+		{URL: "http://localhost:8000", Template: "{{.Version"},
+		{URL: "http://localhost:8000", Secret: "${MISSING_HOOK_SECRET}"},
+	}
+	for _, v := range data {
+		if _, err := newWebhookNotifier(v, nil); err == nil {
+			t.Error("should fail, config:", v)
+		}
+	}
+}
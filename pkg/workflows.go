@@ -16,13 +16,15 @@ import (
 const (
 	triggeredMessage string = "triggered"
 	deployedMessage  string = "deployed"
+	rollbackMessage  string = "rollback"
 )
 
 var errNoExcution = errors.New("noexec")
 
 type event struct {
-	id   string
-	envs envVars
+	id    string
+	envs  envVars
+	trace spanContext
 }
 
 func (r *defaultContainer) createAndStartWorkflows(
@@ -31,23 +33,38 @@ func (r *defaultContainer) createAndStartWorkflows(
 	git gitCommand,
 	startTrigger chan event,
 	startRelease chan event,
-	switchUpstream func(string)) error {
-	slack := newSlackNotifier(r.config.Notifier.Slack)
+	switchUpstream func(version, upstream string)) error {
+	notifiers := r.getNotifiers()
 	err := git.cloneRepository()
 	if err != nil {
 		r.log.Error(err, "error cloning repository")
 		return err
 	}
+	checks, err := newChecks(r.config.Release.Verify, r.config.Main.Runtime, r.secrets)
+	if err != nil {
+		r.log.Error(err, "invalid verification of the release")
+		return err
+	}
+	defer r.getTracer().flush()
 	g, ctx := errgroup.WithContext(ctx)
 	ctx, cancel := context.WithCancel(ctx)
+	runtime := r.config.Main.Runtime
 	install := r.config.Deploy.Install
 	install.name = "install"
+	install.runtime = runtime
+	install.secrets = r.secrets
 	test := r.config.Deploy.Test
 	test.name = "test"
+	test.runtime = runtime
+	test.secrets = r.secrets
 	preBuild := r.config.Deploy.PreBuild
 	preBuild.name = "prebuild"
+	preBuild.runtime = runtime
+	preBuild.secrets = r.secrets
 	build := r.config.Deploy.Build
 	build.name = "build"
+	build.runtime = runtime
+	build.secrets = r.secrets
 	deploy := &deployWorkflow{
 		deployStruct: r.config.Deploy,
 		workspace:    git.getWorkspace(),
@@ -59,9 +76,13 @@ func (r *defaultContainer) createAndStartWorkflows(
 			"pre_build": preBuild,
 			"build":     build,
 		},
-		flow:  []string{"install", "test", "pre_build", "build"},
-		state: &stateDefaultClient{notifier: state.notifier},
-		slack: slack,
+		flow:      []string{"install", "test", "pre_build", "build"},
+		state:     &stateDefaultClient{notifier: state.notifier},
+		notifiers: notifiers,
+		cache:     newDeployCache(r.config.Deploy.Cache, path.Join(git.getExecdir(), ".cache")),
+		artifacts: r.getArtifacts(),
+		metrics:   r.getMetrics(),
+		tracer:    r.getTracer(),
 	}
 	trigger := &triggerWorkflow{
 		triggerStruct: r.config.Trigger,
@@ -70,9 +91,14 @@ func (r *defaultContainer) createAndStartWorkflows(
 		git:           git,
 		command:       &defaultTriggerCommand{},
 		state:         &stateDefaultClient{notifier: state.notifier},
+		notifiers:     notifiers,
+		metrics:       r.getMetrics(),
+		tracer:        r.getTracer(),
 	}
 	run := r.config.Release.Run
 	run.name = "run"
+	run.runtime = runtime
+	run.secrets = r.secrets
 	release := &releaseWorkflow{
 		releaseStruct: r.config.Release,
 		log:           r.log,
@@ -82,14 +108,21 @@ func (r *defaultContainer) createAndStartWorkflows(
 		},
 		flow:           "run",
 		processes:      map[string]*os.Process{},
+		containers:     map[string][]string{},
 		files:          make(map[string][]*file),
 		switchUpstream: switchUpstream,
+		checks:         checks,
+		verifyBackoff:  verifyBackoff,
 		state:          &stateDefaultClient{notifier: state.notifier},
-		slack:          slack,
+		notifiers:      notifiers,
+		artifacts:      r.getArtifacts(),
+		metrics:        r.getMetrics(),
+		tracer:         r.getTracer(),
 	}
 	startDeploy := make(chan event)
 	defer close(startDeploy)
 	g.Go(func() error { return state.start(ctx) })
+	g.Go(func() error { return notifiers.start(ctx) })
 	g.Go(func() error { return trigger.start(ctx, startTrigger, startDeploy) })
 	g.Go(func() error { return deploy.start(ctx, startDeploy, startRelease, startTrigger) })
 	g.Go(func() error { return release.start(ctx, startRelease) })
@@ -99,28 +132,72 @@ func (r *defaultContainer) createAndStartWorkflows(
 }
 
 type workflow struct {
-	log     logr.Logger
-	version string
-	name    string
-	basedir string
-	envs    envVars
-	state   stateClient
+	log       logr.Logger
+	version   string
+	name      string
+	basedir   string
+	envs      envVars
+	state     stateClient
+	cache     string
+	tracer    *tracer
+	trace     spanContext
+	notifiers *notifiers
+}
+
+// startSpan starts the span of a step, child of the span of the push.
+func (w *workflow) startSpan(e *execStruct) *span {
+	s := w.tracer.start(w.trace, w.name+"."+e.name, spanKindInternal)
+	s.setAttribute("crzy.version", w.version)
+	s.setAttribute("crzy.step", e.name)
+	return s
+}
+
+// skip records the step as done from the cache without running it.
+func (w *workflow) skip(e *execStruct) {
+	s := w.startSpan(e)
+	s.setAttribute("crzy.cache", cacheHit)
+	defer s.finish(nil)
+	start := time.Now()
+	duration := "0ms"
+	w.state.notifyStep(
+		w.version,
+		w.name,
+		runnerStatusDone,
+		step{
+			execStruct: *e,
+			Name:       e.name,
+			StartTime:  &start,
+			Duration:   &duration,
+			Cache:      cacheHit,
+			Variables:  w.envs,
+		})
 }
 
 func (w *workflow) execute(e *execStruct) (*envVar, error) {
 	if e == nil {
 		return nil, errNoExcution
 	}
+	s := w.startSpan(e)
 	cmd, err := e.prepare(w.basedir, w.envs)
 	if err != nil {
+		s.finish(err)
 		return nil, err
 	}
 	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	if w.cache != "" {
+		s.setAttribute("crzy.cache", w.cache)
+	}
+	s.finish(err)
 	status := runnerStatusDone
 	duration := fmt.Sprintf("%dms", time.Since(start).Milliseconds())
 	if err != nil {
 		status = runnerStatusFailed
+		n := newNotification(eventStepFailed, w.envs)
+		n.Step = e.name
+		n.Error = err.Error()
+		n.Output = tail(string(output), notificationTailLines)
+		w.notifiers.notify(n)
 	}
 	w.state.notifyStep(
 		w.version,
@@ -131,11 +208,18 @@ func (w *workflow) execute(e *execStruct) (*envVar, error) {
 			Name:       e.name,
 			StartTime:  &start,
 			Duration:   &duration,
+			Cache:      w.cache,
 			Variables:  w.envs,
 		})
 	results := strings.Split(string(output), "\n")
+	// the output of a failed step is always logged so that it can be fixed
+	lines := w.log.V(1)
+	if err != nil {
+		w.log.Error(err, "step failed", "data", e.name)
+		lines = w.log
+	}
 	for _, v := range results {
-		w.log.Info(v)
+		lines.Info(v)
 	}
 	if err != nil {
 		return nil, err
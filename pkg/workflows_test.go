@@ -2,8 +2,10 @@ package pkg
 
 import (
 	"context"
+	"errors"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,7 +35,7 @@ func Test_workflowsAndCancel(t *testing.T) {
 	startRelease := make(chan event)
 	defer close(startRelease)
 	git := &mockGitSuccessCommand{}
-	f := func(port string) {}
+	f := func(version, upstream string) {}
 	g.Go(func() error {
 		return r.createAndStartWorkflows(ctx, &stateManager{
 			notifier: make(chan stepEvent),
@@ -73,7 +75,7 @@ func Test_workflowsAndFail(t *testing.T) {
 	startRelease := make(chan event)
 	defer close(startRelease)
 	git := &mockGitFailCommand{}
-	f := func(port string) {}
+	f := func(version, upstream string) {}
 	err := r.createAndStartWorkflows(
 		context.TODO(),
 		&stateManager{
@@ -158,7 +160,7 @@ func Test_execute_and_fail_command(t *testing.T) {
 		Output:  "",
 	}
 	_, err := workflow.execute(e)
-	if err != errMissingEnv {
+	if !errors.Is(err, errMissingEnv) {
 		t.Error(err, "should fail")
 	}
 }
@@ -188,6 +190,31 @@ func Test_execute_and_fail_combinedoutput(t *testing.T) {
 	}
 }
 
+func Test_execute_and_log_failed_output(t *testing.T) {
+	logger := &log.MockLogger{}
+	workflow := &workflow{
+		log:     logger,
+		version: "version",
+		name:    "deploy",
+		basedir: ".",
+		envs:    envVars{},
+		state:   &stateMockClient{},
+	}
+	e := &execStruct{
+		log:     &log.MockLogger{},
+		name:    "test",
+		Command: "sh",
+		Args:    []string{"-c", "echo broken; exit 1"},
+		WorkDir: ".",
+	}
+	if _, err := workflow.execute(e); err == nil {
+		t.Error("should fail")
+	}
+	if strings.Join(logger.Logs, "\n") != "step failed\nbroken\n" {
+		t.Errorf("should log the output of the failed step, current: %q", logger.Logs)
+	}
+}
+
 func Test_execute_without_exec(t *testing.T) {
 	workflow := &workflow{
 		log:     &log.MockLogger{},
@@ -264,7 +291,7 @@ func Test_start_and_fail_command(t *testing.T) {
 		Envs:    envVars{},
 	}
 	_, err := workflow.start(e)
-	if err != errMissingEnv {
+	if !errors.Is(err, errMissingEnv) {
 		t.Error(err, "should fail")
 	}
 }
@@ -304,11 +331,6 @@ func Test_start_with_envs(t *testing.T) {
 		e.Command = "powershell"
 		e.Args = []string{"-Command", "Get-Content config.go -Wait"}
 	}
-	_, err := e.Envs.toMap()
-	if err != nil {
-		t.Error(err, "should be able to convert envs")
-		t.FailNow()
-	}
 	dir, err := os.MkdirTemp("", "")
 	if err != nil {
 		t.Error(err, "start failed")